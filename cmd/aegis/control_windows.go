@@ -0,0 +1,6 @@
+package main
+
+// checkPrivateDir is a no-op, directory ownership is not checked on Windows.
+func checkPrivateDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/keystore"
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+	"cpl.li/go/cryptor/internal/crypt/pbkdf2"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"golang.org/x/term"
+)
+
+// passwordEnv is the environment variable read for keystore passwords instead
+// of prompting on the terminal.
+const passwordEnv = "AEGIS_PASSWORD"
+
+const encodingUsage = "key encoding, hex, base64, base64url, base32 or base58"
+
+func cmdGenkey(args []string) error {
+	flags := flag.NewFlagSet("genkey", flag.ExitOnError)
+	keystorePath := flags.String("keystore", "",
+		"save the key to a password encrypted keystore and print the public key")
+	kdfName := flags.String("kdf", "pbkdf2",
+		"keystore key derivation, pbkdf2, argon2id or encoded parameters")
+	encName := flags.String("encoding", "hex", encodingUsage)
+	flags.Parse(args)
+
+	enc, err := codec.ParseEncoding(*encName)
+	if err != nil {
+		return err
+	}
+
+	kdf, err := parseKDF(*kdfName)
+	if err != nil {
+		return err
+	}
+
+	var sk ppk.PrivateKey
+	if err := ppk.NewPrivateKey(&sk); err != nil {
+		return err
+	}
+
+	if *keystorePath == "" {
+		fmt.Println(sk.Encode(enc))
+		return nil
+	}
+
+	if _, err := os.Lstat(*keystorePath); err == nil {
+		return fmt.Errorf("keystore %s already exists", *keystorePath)
+	}
+
+	password, err := readPassword("Keystore password: ")
+	if err != nil {
+		return err
+	}
+	if os.Getenv(passwordEnv) == "" {
+		confirm, err := readPassword("Repeat password: ")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(password, confirm) {
+			return errors.New("passwords do not match")
+		}
+	}
+
+	if err := keystore.Save(*keystorePath, &sk, password, kdf); err != nil {
+		return err
+	}
+
+	var pk ppk.PublicKey
+	if err := sk.PublicKey(&pk); err != nil {
+		return err
+	}
+
+	fmt.Println(pk.Encode(enc))
+
+	return nil
+}
+
+// parseKDF returns the keystore KDF named by the -kdf flag.
+func parseKDF(name string) (pbkdf2.PasswordKDF, error) {
+	switch name {
+	case "pbkdf2":
+		return pbkdf2.DefaultPBKDF2, nil
+	case "argon2id":
+		return pbkdf2.DefaultArgon2id, nil
+	}
+
+	return pbkdf2.Parse(name)
+}
+
+func cmdPubkey(args []string) error {
+	flags := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	encName := flags.String("encoding", "hex", encodingUsage)
+	flags.Parse(args)
+
+	enc, err := codec.ParseEncoding(*encName)
+	if err != nil {
+		return err
+	}
+
+	var (
+		sk ppk.PrivateKey
+		pk ppk.PublicKey
+	)
+
+	if err := readPrivateKey(os.Stdin, &sk); err != nil {
+		return err
+	}
+	if err := sk.PublicKey(&pk); err != nil {
+		return err
+	}
+
+	fmt.Println(pk.Encode(enc))
+
+	return nil
+}
+
+func cmdFingerprint(args []string) error {
+	flags := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	peer := flags.String("peer", "",
+		"public key of a peer, print the safety number shared with it instead")
+	flags.Parse(args)
+
+	var (
+		sk ppk.PrivateKey
+		pk ppk.PublicKey
+	)
+
+	if err := readPrivateKey(os.Stdin, &sk); err != nil {
+		return err
+	}
+	if err := sk.PublicKey(&pk); err != nil {
+		return err
+	}
+
+	if *peer != "" {
+		var peerPub ppk.PublicKey
+		if err := peerPub.UnmarshalText([]byte(*peer)); err != nil {
+			return err
+		}
+
+		fmt.Println(ppk.SafetyNumber(&pk, &peerPub))
+
+		return nil
+	}
+
+	var fp ppk.Fingerprint
+	pk.Fingerprint(&fp)
+
+	fmt.Println(fp.Digits())
+	fmt.Println(strings.Join(fp.Words(), " "))
+
+	return nil
+}
+
+func cmdMnemonic(args []string) error {
+	flags := flag.NewFlagSet("mnemonic", flag.ExitOnError)
+	decode := flags.Bool("decode", false,
+		"read a mnemonic from stdin and print the private key as hex")
+	flags.Parse(args)
+
+	var sk ppk.PrivateKey
+
+	if *decode {
+		line, err := readLine(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		mnemonic, err := mwords.MnemonicFromString(line)
+		if err != nil {
+			return err
+		}
+		if err := sk.FromMnemonic(mnemonic); err != nil {
+			return err
+		}
+
+		fmt.Println(sk.ToHex())
+
+		return nil
+	}
+
+	if err := readPrivateKey(os.Stdin, &sk); err != nil {
+		return err
+	}
+
+	fmt.Println(sk.ToMnemonic())
+
+	return nil
+}
+
+// readPrivateKey reads an encoded private key from the first line of r.
+func readPrivateKey(r io.Reader, sk *ppk.PrivateKey) error {
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+
+	return sk.UnmarshalText([]byte(line))
+}
+
+// loadPrivateKey reads an encoded private key from the file at path.
+func loadPrivateKey(path string, sk *ppk.PrivateKey) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return sk.UnmarshalText(bytes.TrimSpace(data))
+}
+
+// loadKeystore decrypts the keystore file at path, prompting for its password.
+func loadKeystore(path string, sk *ppk.PrivateKey) error {
+	password, err := readPassword("Keystore password: ")
+	if err != nil {
+		return err
+	}
+
+	return keystore.Load(path, password, sk)
+}
+
+// readPassword returns the password from the environment, or prompts for it
+// on the terminal.
+func readPassword(prompt string) ([]byte, error) {
+	if password := os.Getenv(passwordEnv); password != "" {
+		return []byte(password), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	return term.ReadPassword(int(os.Stdin.Fd()))
+}
+
+func readLine(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("no input")
+	}
+
+	return strings.TrimSpace(scanner.Text()), nil
+}
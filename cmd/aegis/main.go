@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"cpl.li/go/cryptor"
+)
+
+const usage = `aegis is the main Cryptor client.
+
+Usage:
+
+	aegis <command> [arguments]
+
+Commands:
+
+	genkey      generate a new private key and print it as hex
+	pubkey      read a private key from stdin and print its public key
+	mnemonic    read a private key from stdin and print it as a mnemonic
+	fingerprint read a private key from stdin and print its fingerprint
+	up          run a node answering handshakes on a UDP socket
+	status      print the status of a running node
+	version     print the Cryptor version
+`
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"genkey":      cmdGenkey,
+	"pubkey":      cmdPubkey,
+	"mnemonic":    cmdMnemonic,
+	"fingerprint": cmdFingerprint,
+	"up":          cmdUp,
+	"status":      cmdStatus,
+	"version":     cmdVersion,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "aegis: unknown command %q\n\n", os.Args[1])
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "aegis %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func cmdVersion(args []string) error {
+	fmt.Println("aegis " + cryptor.Version)
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"cpl.li/go/cryptor/internal/config"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+	"cpl.li/go/cryptor/internal/node"
+)
+
+const defaultListen = ":7475"
+
+var defaultControl = defaultControlPath()
+
+// defaultControlPath returns the control socket path in a directory private to
+// the current user, preferring the user runtime directory.
+func defaultControlPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "aegis", "aegis.sock")
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "aegis", "aegis.sock")
+	}
+
+	return filepath.Join(os.TempDir(),
+		fmt.Sprintf("aegis-%d", os.Getuid()), "aegis.sock")
+}
+
+// peerFlag collects peers given as "PUBLICKEY" or "PUBLICKEY@HOST:PORT".
+type peerFlag []string
+
+func (pf *peerFlag) String() string {
+	return strings.Join(*pf, ",")
+}
+
+func (pf *peerFlag) Set(value string) error {
+	*pf = append(*pf, value)
+	return nil
+}
+
+type peerArg struct {
+	pub  ppk.PublicKey
+	psk  *[ppk.KeySize]byte
+	addr *net.UDPAddr
+}
+
+func parsePeer(value string) (peer peerArg, err error) {
+	split := strings.SplitN(value, "@", 2)
+
+	if err := peer.pub.UnmarshalText([]byte(split[0])); err != nil {
+		return peer, fmt.Errorf("peer %q: %v", value, err)
+	}
+
+	if len(split) == 2 {
+		peer.addr, err = net.ResolveUDPAddr("udp", split[1])
+		if err != nil {
+			return peer, fmt.Errorf("peer %q: %v", value, err)
+		}
+	}
+
+	return peer, nil
+}
+
+func cmdUp(args []string) error {
+	var peerFlags peerFlag
+
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	listen := flags.String("listen", defaultListen, "UDP address to listen on")
+	keyPath := flags.String("key", "", "file containing the hex private key")
+	keystorePath := flags.String("keystore", "",
+		"password encrypted keystore containing the private key")
+	cfgPath := flags.String("config", "",
+		"node configuration file, replaces -key, -listen and -peer")
+	control := flags.String("control", defaultControl,
+		"unix socket answering status requests")
+	flags.Var(&peerFlags, "peer",
+		"allowed peer as PUBLICKEY or PUBLICKEY@HOST:PORT, repeatable")
+	flags.Parse(args)
+
+	if *cfgPath != "" {
+		return runConfig(*cfgPath, *control)
+	}
+
+	var sk ppk.PrivateKey
+
+	switch {
+	case *keystorePath != "":
+		if err := loadKeystore(*keystorePath, &sk); err != nil {
+			return err
+		}
+	case *keyPath != "":
+		if err := loadPrivateKey(*keyPath, &sk); err != nil {
+			return err
+		}
+	default:
+		return errors.New("missing -key, -keystore or -config")
+	}
+
+	peers := make([]peerArg, 0, len(peerFlags))
+	for _, value := range peerFlags {
+		peer, err := parsePeer(value)
+		if err != nil {
+			return err
+		}
+		peers = append(peers, peer)
+	}
+
+	return run(&sk, *listen, *control, peers)
+}
+
+func runConfig(path, control string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	peers := make([]peerArg, 0, len(cfg.Peers))
+	for _, peer := range cfg.Peers {
+		arg := peerArg{
+			pub: peer.PublicKey,
+			psk: peer.PresharedKey,
+		}
+
+		if peer.Endpoint != "" {
+			arg.addr, err = net.ResolveUDPAddr("udp", peer.Endpoint)
+			if err != nil {
+				return err
+			}
+		}
+
+		peers = append(peers, arg)
+	}
+
+	return run(&cfg.PrivateKey, cfg.ListenAddress, control, peers)
+}
+
+func run(sk *ppk.PrivateKey, listen, control string, peers []peerArg) error {
+	n, err := node.New(sk, listen)
+	if err != nil {
+		return err
+	}
+	defer n.Close()
+
+	for _, peer := range peers {
+		if err := n.AddPeer(&peer.pub, peer.psk); err != nil {
+			return err
+		}
+	}
+
+	ctl, err := listenControl(control, n)
+	if err != nil {
+		return err
+	}
+	defer ctl.Close()
+
+	pub := n.PublicKey()
+	fmt.Printf("aegis listening on %s as %s\n", n.Addr(), pub.ToHex())
+
+	for _, peer := range peers {
+		if peer.addr == nil {
+			continue
+		}
+		go func(peer peerArg) {
+			if err := n.Connect(peer.pub, peer.addr); err != nil {
+				fmt.Fprintf(os.Stderr, "connect %s: %v\n", peer.pub.ToHex(), err)
+			}
+		}(peer)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case packet, ok := <-n.Recv():
+			if !ok {
+				return nil
+			}
+			fmt.Printf("%s: %q\n", packet.Peer.ToHex(), packet.Data)
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// listenControl serves the node status on a unix socket, one status report
+// per connection. The socket directory must only be accessible by the current
+// user, and is created if missing.
+func listenControl(path string, n *node.Node) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		return nil, err
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			writeStatus(conn, n)
+			conn.Close()
+		}
+	}()
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket left at path by a node which is no
+// longer running. Anything else at path is left untouched.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("control path %s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("control socket %s already in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+func writeStatus(w io.Writer, n *node.Node) {
+	pub := n.PublicKey()
+
+	fmt.Fprintf(w, "public key: %s\n", pub.ToHex())
+	fmt.Fprintf(w, "listening:  %s\n", n.Addr())
+	fmt.Fprintf(w, "peers:      %d\n", n.Peers().Len())
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, peer := range n.Peers().Peers() {
+		pub := peer.PublicKey()
+
+		handshake := "never"
+		if last := peer.LastHandshake(); !last.IsZero() {
+			handshake = time.Since(last).Truncate(time.Second).String() + " ago"
+		}
+
+		session := "none"
+		if peer.Session() != nil {
+			session = "active"
+		}
+
+		fmt.Fprintf(tw, "\n  %s\tsession: %s\thandshake: %s", pub.ToHex(),
+			session, handshake)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+func cmdStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	control := flags.String("control", defaultControl,
+		"unix socket of the running node")
+	flags.Parse(args)
+
+	conn, err := net.Dial("unix", *control)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = io.Copy(os.Stdout, conn)
+
+	return err
+}
@@ -2,10 +2,50 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"cpl.li/go/cryptor"
 )
 
+const usage = `proto holds Cryptor prototyping and operator tools.
+
+Usage:
+
+	proto <command> [arguments]
+
+Commands:
+
+	vanity      search for a key pair whose public key matches a prefix or regex
+	version     print the Cryptor version
+`
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"vanity":  cmdVanity,
+	"version": cmdVersion,
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "proto: unknown command %q\n\n", os.Args[1])
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "proto %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func cmdVersion(args []string) error {
 	fmt.Println("cryptor " + cryptor.Version)
+	return nil
 }
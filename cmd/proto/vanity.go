@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/vanity"
+)
+
+func cmdVanity(args []string) error {
+	flags := flag.NewFlagSet("vanity", flag.ExitOnError)
+	prefix := flags.String("prefix", "", "prefix the encoded public key must start with")
+	pattern := flags.String("regex", "", "regular expression the encoded public key must match")
+	encName := flags.String("encoding", "hex",
+		"public key encoding matched and printed, hex, base64, base64url, base32 or base58")
+	workers := flags.Int("workers", 0, "number of workers, one per CPU if 0")
+	timeout := flags.Duration("timeout", 0, "give up after the given duration, never if 0")
+	interval := flags.Duration("progress", 5*time.Second,
+		"interval between progress reports on stderr, none if 0")
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	enc, err := codec.ParseEncoding(*encName)
+	if err != nil {
+		return err
+	}
+
+	opts := vanity.Options{
+		Encoding: enc,
+		Prefix:   *prefix,
+		Workers:  *workers,
+	}
+
+	if *pattern != "" {
+		if opts.Pattern, err = regexp.Compile(*pattern); err != nil {
+			return err
+		}
+	}
+
+	if *interval > 0 {
+		opts.ProgressInterval = *interval
+		opts.Progress = printProgress
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	result, err := vanity.Search(ctx, opts)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("no match found before stopping")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "found after %d keys in %s\n",
+		result.Attempts, result.Elapsed.Truncate(time.Millisecond))
+	fmt.Println(result.PrivateKey.Encode(enc))
+	fmt.Println(result.PublicKey.Encode(enc))
+
+	return nil
+}
+
+func printProgress(progress vanity.Progress) {
+	line := fmt.Sprintf("%d keys in %s, %.0f keys/s",
+		progress.Attempts, progress.Elapsed.Truncate(time.Second), progress.Rate)
+
+	if progress.Expected > 0 {
+		line += fmt.Sprintf(", %.0f expected", progress.Expected)
+		if progress.ETA >= time.Second {
+			line += ", eta " + progress.ETA.Truncate(time.Second).String()
+		} else if progress.ETA > 0 {
+			line += ", eta <1s"
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, line)
+}
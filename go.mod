@@ -5,5 +5,5 @@ go 1.14
 require (
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/crypto v0.0.0-20200406173513-056763e48d71
-	golang.org/x/sys v0.0.0-20200409092240-59c9f1ba88fa // indirect
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 )
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// Config describes a node: its identity, where it listens and which peers it
+// accepts. It is parsed from an INI like file:
+//
+//	# comments start with '#'
+//	[Node]
+//	PrivateKey = <key or 24 word mnemonic>
+//	ListenAddress = 0.0.0.0:7475
+//
+//	[Peer]
+//	PublicKey = <key>
+//	PresharedKey = <key, optional>
+//	Endpoint = <host:port, optional>
+//
+// Keys are hex, base64, base64url, base32 or base58 encoded.
+// The [Node] section must appear exactly once, [Peer] any number of times.
+// Section and key names are case insensitive.
+type Config struct {
+	PrivateKey    ppk.PrivateKey
+	ListenAddress string
+	Peers         []Peer
+}
+
+// Peer is a [Peer] section of a Config.
+type Peer struct {
+	PublicKey    ppk.PublicKey
+	PresharedKey *[ppk.KeySize]byte
+	Endpoint     string
+}
+
+// ErrMissingNode is returned for configuration files without a [Node] section.
+var ErrMissingNode = errors.New("missing [Node] section")
+
+// ParseError is returned for invalid configuration files, pointing at the line
+// at fault.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+const (
+	sectionNode = "node"
+	sectionPeer = "peer"
+)
+
+// section collects the keys of a section while parsing.
+type section struct {
+	name  string
+	line  int
+	keys  map[string]value
+	order []string
+}
+
+type value struct {
+	text string
+	line int
+}
+
+// Load parses and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse parses and validates a configuration file.
+func Parse(r io.Reader) (*Config, error) {
+	sections, err := split(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cfg  Config
+		node *section
+		seen = make(map[ppk.PublicKey]int)
+	)
+
+	for _, sec := range sections {
+		switch sec.name {
+		case sectionNode:
+			if node != nil {
+				return nil, &ParseError{sec.line, errors.New("duplicate [Node] section")}
+			}
+			node = sec
+			if err := parseNode(sec, &cfg); err != nil {
+				return nil, err
+			}
+		case sectionPeer:
+			peer, err := parsePeer(sec)
+			if err != nil {
+				return nil, err
+			}
+			if line, ok := seen[peer.PublicKey]; ok {
+				return nil, &ParseError{sec.line,
+					fmt.Errorf("duplicate peer, first defined on line %d", line)}
+			}
+			seen[peer.PublicKey] = sec.line
+			cfg.Peers = append(cfg.Peers, peer)
+		}
+	}
+
+	if node == nil {
+		return nil, ErrMissingNode
+	}
+
+	var pub ppk.PublicKey
+	if err := cfg.PrivateKey.PublicKey(&pub); err != nil {
+		return nil, err
+	}
+	if line, ok := seen[pub]; ok {
+		return nil, &ParseError{line, errors.New("peer is the node itself")}
+	}
+
+	return &cfg, nil
+}
+
+// split reads the file into sections, rejecting malformed lines, unknown
+// sections and duplicate keys.
+func split(r io.Reader) ([]*section, error) {
+	var (
+		sections []*section
+		current  *section
+		line     int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+
+		text := scanner.Text()
+		if index := strings.IndexByte(text, '#'); index >= 0 {
+			text = text[:index]
+		}
+		text = strings.TrimSpace(text)
+
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, &ParseError{line, errors.New("malformed section header")}
+			}
+
+			name := strings.ToLower(strings.TrimSpace(text[1 : len(text)-1]))
+			if name != sectionNode && name != sectionPeer {
+				return nil, &ParseError{line, fmt.Errorf("unknown section %s", text)}
+			}
+
+			current = &section{
+				name: name,
+				line: line,
+				keys: make(map[string]value),
+			}
+			sections = append(sections, current)
+
+			continue
+		}
+
+		index := strings.IndexByte(text, '=')
+		if index < 0 {
+			return nil, &ParseError{line, errors.New("expected key = value")}
+		}
+		if current == nil {
+			return nil, &ParseError{line, errors.New("key outside of a section")}
+		}
+
+		key := strings.ToLower(strings.TrimSpace(text[:index]))
+		val := strings.TrimSpace(text[index+1:])
+
+		if key == "" {
+			return nil, &ParseError{line, errors.New("empty key")}
+		}
+		if val == "" {
+			return nil, &ParseError{line, fmt.Errorf("empty value for %s", key)}
+		}
+		if prev, ok := current.keys[key]; ok {
+			return nil, &ParseError{line,
+				fmt.Errorf("duplicate key %s, first defined on line %d", key, prev.line)}
+		}
+
+		current.keys[key] = value{text: val, line: line}
+		current.order = append(current.order, key)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+func parseNode(sec *section, cfg *Config) error {
+	for _, key := range sec.order {
+		var (
+			val = sec.keys[key]
+			err error
+		)
+
+		switch key {
+		case "privatekey":
+			err = parsePrivateKey(val.text, &cfg.PrivateKey)
+		case "listenaddress":
+			_, err = checkAddress(val.text)
+			cfg.ListenAddress = val.text
+		default:
+			err = fmt.Errorf("unknown key %s in [Node]", key)
+		}
+
+		if err != nil {
+			return &ParseError{val.line, err}
+		}
+	}
+
+	if _, ok := sec.keys["privatekey"]; !ok {
+		return &ParseError{sec.line, errors.New("missing PrivateKey in [Node]")}
+	}
+	if _, ok := sec.keys["listenaddress"]; !ok {
+		return &ParseError{sec.line, errors.New("missing ListenAddress in [Node]")}
+	}
+
+	return nil
+}
+
+func parsePeer(sec *section) (peer Peer, err error) {
+	for _, key := range sec.order {
+		val := sec.keys[key]
+
+		switch key {
+		case "publickey":
+			err = peer.PublicKey.UnmarshalText([]byte(val.text))
+			if err == nil && peer.PublicKey.IsZero() {
+				err = errors.New("zero public key")
+			}
+		case "presharedkey":
+			peer.PresharedKey = new([ppk.KeySize]byte)
+			err = (*ppk.PrivateKey)(peer.PresharedKey).UnmarshalText([]byte(val.text))
+		case "endpoint":
+			err = checkEndpoint(val.text)
+			peer.Endpoint = val.text
+		default:
+			err = fmt.Errorf("unknown key %s in [Peer]", key)
+		}
+
+		if err != nil {
+			return peer, &ParseError{val.line, err}
+		}
+	}
+
+	if _, ok := sec.keys["publickey"]; !ok {
+		return peer, &ParseError{sec.line, errors.New("missing PublicKey in [Peer]")}
+	}
+
+	return peer, nil
+}
+
+// parsePrivateKey accepts an encoded key or a 24 word mnemonic.
+func parsePrivateKey(text string, sk *ppk.PrivateKey) error {
+	if fields := strings.Fields(text); len(fields) > 1 {
+		if err := sk.FromMnemonic(mwords.MnemonicSentence(fields)); err != nil {
+			return err
+		}
+	} else if err := sk.UnmarshalText([]byte(text)); err != nil {
+		return err
+	}
+
+	if sk.IsZero() {
+		return errors.New("zero private key")
+	}
+
+	return nil
+}
+
+// checkAddress validates a host:port address without resolving the host,
+// returning the port. Port 0 picks an ephemeral port when listening.
+func checkAddress(addr string) (uint16, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	number, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+
+	return uint16(number), nil
+}
+
+// checkEndpoint validates the address of a peer, which needs a known port.
+func checkEndpoint(addr string) error {
+	port, err := checkAddress(addr)
+	if err != nil {
+		return err
+	}
+	if port == 0 {
+		return errors.New("port must not be 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/config"
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+const (
+	testPrivateKey = "e8b6b5c0a1c5b0cdbd2a7e6f15b8d3d1c7c1d1e3a8f0a3b21a0c3e9d5b4f6a71"
+	testPeer0      = "a5f686a01f0327c2a1bce2d2ae01c4174d1637fd31a5a065d0b235ea37cc3d74"
+	testPeer1      = "9ef034f42268c5be607d0bd16ccd259ed7e567303a929ad32279903ca9e4fb11"
+	testPSK        = "93b5fb7506799aaeb93b91cff9fbbcf798d26df95c6d579fce45af881a0b471b"
+)
+
+var testConfig = `
+# node identity
+[Node]
+PrivateKey = ` + testPrivateKey + `
+ListenAddress = 0.0.0.0:7475
+
+[Peer]
+PublicKey = ` + testPeer0 + ` # first peer
+PresharedKey = ` + testPSK + `
+Endpoint = example.com:7475
+
+[peer]
+publickey = ` + testPeer1 + `
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	assert.NoError(t, err)
+
+	assert.Equal(t, testPrivateKey, cfg.PrivateKey.ToHex())
+	assert.Equal(t, "0.0.0.0:7475", cfg.ListenAddress)
+	assert.Len(t, cfg.Peers, 2)
+
+	assert.Equal(t, testPeer0, cfg.Peers[0].PublicKey.ToHex())
+	assert.NotNil(t, cfg.Peers[0].PresharedKey)
+	assert.Equal(t, testPSK,
+		(*ppk.PrivateKey)(cfg.Peers[0].PresharedKey).ToHex())
+	assert.Equal(t, "example.com:7475", cfg.Peers[0].Endpoint)
+
+	assert.Equal(t, testPeer1, cfg.Peers[1].PublicKey.ToHex())
+	assert.Nil(t, cfg.Peers[1].PresharedKey)
+	assert.Empty(t, cfg.Peers[1].Endpoint)
+}
+
+func TestParseMnemonic(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.PrivateKey
+	assert.NoError(t, sk.FromHex(testPrivateKey))
+
+	cfg, err := config.Parse(strings.NewReader(
+		"[Node]\nPrivateKey = " + sk.ToMnemonic().String() +
+			"\nListenAddress = :7475\n"))
+	assert.NoError(t, err)
+	assert.True(t, sk.Equals(cfg.PrivateKey))
+	assert.Empty(t, cfg.Peers)
+}
+
+func TestParseEncodings(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sk         ppk.PrivateKey
+		peer0, psk ppk.PublicKey
+	)
+	assert.NoError(t, sk.FromHex(testPrivateKey))
+	assert.NoError(t, peer0.FromHex(testPeer0))
+	assert.NoError(t, psk.FromHex(testPSK))
+
+	cfg, err := config.Parse(strings.NewReader(
+		"[Node]\nPrivateKey = " + sk.Encode(codec.Base64) +
+			"\nListenAddress = :7475\n" +
+			"[Peer]\nPublicKey = " + peer0.Encode(codec.Base58) +
+			"\nPresharedKey = " + psk.Encode(codec.Base32) + "\n"))
+	assert.NoError(t, err)
+	assert.True(t, sk.Equals(cfg.PrivateKey))
+	assert.True(t, peer0.Equals(cfg.Peers[0].PublicKey))
+	assert.Equal(t, testPSK,
+		(*ppk.PrivateKey)(cfg.Peers[0].PresharedKey).ToHex())
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "cryptor-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "node.conf")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testConfig), 0600))
+
+	cfg, err := config.Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Peers, 2)
+
+	_, err = config.Load(filepath.Join(dir, "missing.conf"))
+	assert.Error(t, err)
+}
+
+var invalidConfigs = []struct {
+	config string
+	line   int
+}{
+	{"[Node\n", 1},
+	{"[Interface]\n", 1},
+	{"PrivateKey = " + testPrivateKey + "\n", 1},
+	{"[Node]\nPrivateKey\n", 2},
+	{"[Node]\n = value\n", 2},
+	{"[Node]\nPrivateKey =\n", 2},
+	{"[Node]\nListenAddress = :7475\n", 1},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\n", 1},
+	{"[Node]\nPrivateKey = 00\nListenAddress = :7475\n", 2},
+	{"[Node]\nPrivateKey = abandon abandon\nListenAddress = :7475\n", 2},
+	{"[Node]\nPrivateKey = " + strings.Repeat("00", 32) + "\nListenAddress = :7475\n", 2},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = 7475\n", 3},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :port\n", 3},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :70000\n", 3},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\nMTU = 1420\n", 4},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nPrivateKey = " + testPrivateKey + "\n", 3},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Node]\n", 4},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n\n[Peer]\n", 5},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = 00\n", 5},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + strings.Repeat("00", 32) + "\n", 5},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + testPeer0 + "\nPresharedKey = 0102\n", 6},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + testPeer0 + "\nEndpoint = example.com\n", 6},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + testPeer0 + "\nEndpoint = example.com:0\n", 6},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + testPeer0 + "\nAllowedIPs = 10.0.0.0/8\n", 6},
+	{"[Node]\nPrivateKey = " + testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " + testPeer0 + "\n[Peer]\nPublicKey = " + testPeer0 + "\n", 6},
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range invalidConfigs {
+		_, err := config.Parse(strings.NewReader(test.config))
+		if !assert.Error(t, err, test.config) {
+			continue
+		}
+
+		parseErr, ok := err.(*config.ParseError)
+		if assert.True(t, ok, "unexpected error type %T", err) {
+			assert.Equal(t, test.line, parseErr.Line, test.config)
+		}
+	}
+}
+
+func TestParseMissingNode(t *testing.T) {
+	t.Parallel()
+
+	for _, text := range []string{
+		"",
+		"# only comments\n",
+		"[Peer]\nPublicKey = " + testPeer0 + "\n",
+	} {
+		_, err := config.Parse(strings.NewReader(text))
+		assert.Equal(t, config.ErrMissingNode, err, text)
+	}
+}
+
+func TestParseEphemeralPort(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.Parse(strings.NewReader("[Node]\nPrivateKey = " +
+		testPrivateKey + "\nListenAddress = 127.0.0.1:0\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:0", cfg.ListenAddress)
+}
+
+func TestParseSelfPeer(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sk  ppk.PrivateKey
+		pub ppk.PublicKey
+	)
+	assert.NoError(t, sk.FromHex(testPrivateKey))
+	assert.NoError(t, sk.PublicKey(&pub))
+
+	_, err := config.Parse(strings.NewReader("[Node]\nPrivateKey = " +
+		testPrivateKey + "\nListenAddress = :7475\n[Peer]\nPublicKey = " +
+		pub.ToHex() + "\n"))
+	assert.Error(t, err)
+	assert.Equal(t, 4, err.(*config.ParseError).Line)
+	assert.Equal(t, "line 4: peer is the node itself", err.Error())
+}
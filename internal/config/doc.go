@@ -0,0 +1 @@
+package config // import "cpl.li/go/cryptor/internal/config"
@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"errors"
+	"math/big"
+)
+
+// base58Alphabet is the Bitcoin base58 alphabet, leaving out 0, O, I and l.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ErrInvalidBase58 is returned when decoding a string which is not base58.
+var ErrInvalidBase58 = errors.New("invalid base58 string")
+
+var (
+	base58Radix   = big.NewInt(58)
+	base58Indexes [256]int8
+)
+
+func init() {
+	for index := range base58Indexes {
+		base58Indexes[index] = -1
+	}
+	for index := 0; index < len(base58Alphabet); index++ {
+		base58Indexes[base58Alphabet[index]] = int8(index)
+	}
+}
+
+// EncodeBase58 returns the base58 encoding of src. Each leading zero byte is
+// encoded as a leading '1'.
+func EncodeBase58(src []byte) string {
+	zeros := 0
+	for zeros < len(src) && src[zeros] == 0 {
+		zeros++
+	}
+
+	var (
+		value = new(big.Int).SetBytes(src)
+		mod   = new(big.Int)
+		out   []byte
+	)
+
+	for value.Sign() > 0 {
+		value.DivMod(value, base58Radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for ; zeros > 0; zeros-- {
+		out = append(out, base58Alphabet[0])
+	}
+
+	for left, right := 0, len(out)-1; left < right; left, right = left+1, right-1 {
+		out[left], out[right] = out[right], out[left]
+	}
+
+	return string(out)
+}
+
+// DecodeBase58 returns the bytes represented by the base58 string src.
+func DecodeBase58(src string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(src) && src[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	value := new(big.Int)
+	for index := zeros; index < len(src); index++ {
+		digit := base58Indexes[src[index]]
+		if digit < 0 {
+			return nil, ErrInvalidBase58
+		}
+
+		value.Mul(value, base58Radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
@@ -0,0 +1,156 @@
+package codec
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Encoding is a text encoding for keys and hashes.
+type Encoding byte
+
+// Supported encodings. Base64 and Base32 strings are padded.
+const (
+	Hex Encoding = iota
+	Base64
+	Base64URL
+	Base32
+	Base58
+)
+
+var (
+	// ErrUnknownEncoding is returned for unknown encodings.
+	ErrUnknownEncoding = errors.New("unknown encoding")
+
+	// ErrInvalidSize is returned when the decoded bytes don't match the size
+	// of the destination.
+	ErrInvalidSize = errors.New("decoded size does not match")
+)
+
+var encodingNames = [...]string{
+	Hex:       "hex",
+	Base64:    "base64",
+	Base64URL: "base64url",
+	Base32:    "base32",
+	Base58:    "base58",
+}
+
+var encodingAlphabets = [...]string{
+	Hex:       "0123456789abcdef",
+	Base64:    "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/",
+	Base64URL: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+	Base32:    "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567",
+	Base58:    base58Alphabet,
+}
+
+// ParseEncoding returns the encoding with the given name, as returned by
+// String.
+func ParseEncoding(name string) (Encoding, error) {
+	for index, encName := range encodingNames {
+		if strings.EqualFold(name, encName) {
+			return Encoding(index), nil
+		}
+	}
+
+	return 0, ErrUnknownEncoding
+}
+
+// String returns the name of the encoding, e.g. "base64url".
+func (e Encoding) String() string {
+	if int(e) >= len(encodingNames) {
+		return "unknown"
+	}
+
+	return encodingNames[e]
+}
+
+// Alphabet returns the characters used by the encoding, not including
+// padding.
+func (e Encoding) Alphabet() string {
+	if int(e) >= len(encodingAlphabets) {
+		return ""
+	}
+
+	return encodingAlphabets[e]
+}
+
+// Encode returns the encoding of src.
+func (e Encoding) Encode(src []byte) string {
+	switch e {
+	case Hex:
+		return hex.EncodeToString(src)
+	case Base64:
+		return base64.StdEncoding.EncodeToString(src)
+	case Base64URL:
+		return base64.URLEncoding.EncodeToString(src)
+	case Base32:
+		return base32.StdEncoding.EncodeToString(src)
+	case Base58:
+		return EncodeBase58(src)
+	}
+
+	return ""
+}
+
+// Decode decodes src into dst, which must be exactly as long as the decoded
+// bytes.
+func (e Encoding) Decode(dst []byte, src string) error {
+	var (
+		decoded []byte
+		err     error
+	)
+
+	switch e {
+	case Hex:
+		decoded, err = hex.DecodeString(src)
+	case Base64:
+		decoded, err = base64.StdEncoding.DecodeString(src)
+	case Base64URL:
+		decoded, err = base64.URLEncoding.DecodeString(src)
+	case Base32:
+		decoded, err = base32.StdEncoding.DecodeString(src)
+	case Base58:
+		decoded, err = DecodeBase58(src)
+	default:
+		return ErrUnknownEncoding
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(dst) {
+		return ErrInvalidSize
+	}
+
+	copy(dst, decoded)
+
+	return nil
+}
+
+// Detect returns the encoding src most likely uses to encode size bytes. Hex,
+// Base64 and Base32 are told apart by their length, Base64 and Base64URL by
+// their alphabet, and anything else is assumed to be Base58.
+func Detect(src string, size int) Encoding {
+	switch len(src) {
+	case hex.EncodedLen(size):
+		return Hex
+	case base64.StdEncoding.EncodedLen(size):
+		if strings.ContainsAny(src, "-_") {
+			return Base64URL
+		}
+		if strings.HasSuffix(src, "=") || strings.ContainsAny(src, "+/") {
+			return Base64
+		}
+	case base32.StdEncoding.EncodedLen(size):
+		return Base32
+	}
+
+	return Base58
+}
+
+// DecodeAny decodes src into dst using the detected encoding.
+func DecodeAny(dst []byte, src string) error {
+	return Detect(src, len(dst)).Decode(dst, src)
+}
@@ -0,0 +1,120 @@
+package codec_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/codec"
+)
+
+func TestBase58(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		hex, encoded string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"0000", "11"},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"636363", "aPEr"},
+		{"48656c6c6f20576f726c6421", "2NEpo7TZRRrLZSi2U"},
+		{"00000000000000000000", "1111111111"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647",
+			"1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+		{"572e4794", "3EFU7m"},
+	} {
+		data, _ := hex.DecodeString(test.hex)
+
+		assert.Equal(t, test.encoded, codec.EncodeBase58(data))
+
+		decoded, err := codec.DecodeBase58(test.encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, test.hex, hex.EncodeToString(decoded))
+	}
+
+	for _, invalid := range []string{"0", "O", "I", "l", "abc+", "3EFU7m "} {
+		_, err := codec.DecodeBase58(invalid)
+		assert.Equal(t, codec.ErrInvalidBase58, err, invalid)
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	t.Parallel()
+
+	data, _ := hex.DecodeString(
+		"fbff3e1a5ed0b27aac31f0c9b4ff3f9a7d5b49f3ad2e1dcd8d79cd4e2b2c0f3e")
+
+	for _, test := range []struct {
+		enc     codec.Encoding
+		name    string
+		encoded string
+	}{
+		{codec.Hex, "hex",
+			"fbff3e1a5ed0b27aac31f0c9b4ff3f9a7d5b49f3ad2e1dcd8d79cd4e2b2c0f3e"},
+		{codec.Base64, "base64",
+			"+/8+Gl7QsnqsMfDJtP8/mn1bSfOtLh3NjXnNTissDz4="},
+		{codec.Base64URL, "base64url",
+			"-_8-Gl7QsnqsMfDJtP8_mn1bSfOtLh3NjXnNTissDz4="},
+		{codec.Base32, "base32",
+			"7P7T4GS62CZHVLBR6DE3J7Z7TJ6VWSPTVUXB3TMNPHGU4KZMB47A===="},
+	} {
+		encoded := test.encoded
+
+		assert.Equal(t, test.name, test.enc.String())
+		assert.Equal(t, encoded, test.enc.Encode(data))
+
+		decoded := make([]byte, len(data))
+		assert.NoError(t, test.enc.Decode(decoded, encoded))
+		assert.Equal(t, data, decoded)
+
+		parsed, err := codec.ParseEncoding(test.name)
+		assert.NoError(t, err)
+		assert.Equal(t, test.enc, parsed)
+
+		assert.Equal(t, test.enc, codec.Detect(encoded, len(data)))
+
+		for _, char := range strings.TrimRight(encoded, "=") {
+			assert.Contains(t, test.enc.Alphabet(), string(char), test.name)
+		}
+	}
+
+	assert.Equal(t, codec.ErrInvalidSize,
+		codec.Hex.Decode(make([]byte, 31), codec.Hex.Encode(data)))
+	assert.Error(t, codec.Base64.Decode(make([]byte, 32), "not base64"))
+
+	unknown := codec.Encoding(0xFF)
+	assert.Equal(t, "unknown", unknown.String())
+	assert.Empty(t, unknown.Alphabet())
+	assert.Empty(t, unknown.Encode(data))
+	assert.Equal(t, codec.ErrUnknownEncoding, unknown.Decode(data, ""))
+
+	_, err := codec.ParseEncoding("base85")
+	assert.Equal(t, codec.ErrUnknownEncoding, err)
+}
+
+func TestDecodeAny(t *testing.T) {
+	t.Parallel()
+
+	for iter := 0; iter < 256; iter++ {
+		data := crypt.RandomBytes(32)
+		if iter == 0 {
+			data = make([]byte, 32)
+		}
+
+		for _, enc := range []codec.Encoding{
+			codec.Hex, codec.Base64, codec.Base64URL, codec.Base32, codec.Base58,
+		} {
+			decoded := make([]byte, len(data))
+			assert.NoError(t, codec.DecodeAny(decoded, enc.Encode(data)), enc)
+			assert.Equal(t, data, decoded, enc)
+		}
+	}
+}
@@ -0,0 +1 @@
+package codec // import "cpl.li/go/cryptor/internal/crypt/codec"
@@ -0,0 +1,143 @@
+package hashing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
+)
+
+// Algorithm identifies a hash function. The zero value is BLAKE2s, the
+// Cryptor default. All algorithms produce HashSize digests.
+type Algorithm uint8
+
+// Supported hash algorithms.
+const (
+	BLAKE2s Algorithm = iota
+	BLAKE2b
+	SHA256
+	SHA3_256
+)
+
+// Default is the algorithm used by Hash and HashFunction.
+const Default = BLAKE2s
+
+// ErrUnknownAlgorithm is returned for unknown or unsupported algorithms.
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+
+type algorithm struct {
+	name string
+	size int
+	new  func() hash.Hash
+}
+
+var algorithms = [...]algorithm{
+	BLAKE2s: {"BLAKE2s", blake2s.Size, func() hash.Hash {
+		h, _ := blake2s.New256(nil)
+		return h
+	}},
+	BLAKE2b: {"BLAKE2b", blake2b.Size256, func() hash.Hash {
+		h, _ := blake2b.New256(nil)
+		return h
+	}},
+	SHA256:   {"SHA256", sha256.Size, sha256.New},
+	SHA3_256: {"SHA3-256", 32, sha3.New256},
+}
+
+// ParseAlgorithm returns the algorithm with the given name, ignoring case.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for index, alg := range algorithms {
+		if strings.EqualFold(alg.name, name) {
+			return Algorithm(index), nil
+		}
+	}
+
+	return 0, ErrUnknownAlgorithm
+}
+
+// Available returns true if the algorithm is supported.
+func (a Algorithm) Available() bool {
+	return int(a) < len(algorithms)
+}
+
+// New returns a new hash.Hash computing the algorithm. It panics if the
+// algorithm is not available.
+func (a Algorithm) New() hash.Hash {
+	if !a.Available() {
+		panic(ErrUnknownAlgorithm)
+	}
+
+	return algorithms[a].new()
+}
+
+// Size returns the digest size of the algorithm, or 0 if not available.
+func (a Algorithm) Size() int {
+	if !a.Available() {
+		return 0
+	}
+
+	return algorithms[a].size
+}
+
+// String returns the name of the algorithm, e.g. "BLAKE2s".
+func (a Algorithm) String() string {
+	if !a.Available() {
+		return "unknown"
+	}
+
+	return algorithms[a].name
+}
+
+// Sum returns the digest of the data using the algorithm.
+func (a Algorithm) Sum(data ...[]byte) Sum {
+	h := a.New()
+
+	for _, set := range data {
+		h.Write(set)
+	}
+
+	return Sum{Algorithm: a, Digest: h.Sum(nil)}
+}
+
+// Sum is a digest tagged with the algorithm which produced it.
+type Sum struct {
+	Algorithm Algorithm
+	Digest    []byte
+}
+
+// ToHex returns the hex encoding of the digest.
+func (s Sum) ToHex() string {
+	return hex.EncodeToString(s.Digest)
+}
+
+// String returns the algorithm name and hex digest, e.g. "SHA256:e3b0...".
+func (s Sum) String() string {
+	return s.Algorithm.String() + ":" + s.ToHex()
+}
+
+// Equal returns true if both sums use the same algorithm and digest. Digests
+// are compared in constant time.
+func (s Sum) Equal(other Sum) bool {
+	return s.Algorithm == other.Algorithm && hmac.Equal(s.Digest, other.Digest)
+}
+
+// HashWith hashes the data using the algorithm, writing the digest to sum.
+func HashWith(alg Algorithm, sum *HashSum, data ...[]byte) {
+	h := alg.New()
+
+	for _, set := range data {
+		h.Write(set)
+	}
+
+	if sum == nil {
+		return
+	}
+
+	h.Sum(sum[:0])
+}
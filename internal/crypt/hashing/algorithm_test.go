@@ -0,0 +1,100 @@
+package hashing_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+func TestAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		alg      hashing.Algorithm
+		name     string
+		empty    string
+		expected string
+	}{
+		{
+			hashing.BLAKE2s, "BLAKE2s",
+			"69217a3079908094e11121d042354a7c1f55b6482ca1a51e1b250dfd1ed0eef9",
+			"ec9db904d636ef61f1421b2ba47112a4fa6b8964fd4a0a514834455c21df7812",
+		},
+		{
+			hashing.BLAKE2b, "BLAKE2b",
+			"0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8",
+			"511bc81dde11180838c562c82bb35f3223f46061ebde4a955c27b3f489cf1e03",
+		},
+		{
+			hashing.SHA256, "SHA256",
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f",
+		},
+		{
+			hashing.SHA3_256, "SHA3-256",
+			"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+			"1af17a664e3fa8e419b8ba05c2a173169df76162a5a286e0c405b460d478f7ef",
+		},
+	} {
+		assert.True(t, test.alg.Available())
+		assert.Equal(t, test.name, test.alg.String())
+		assert.Equal(t, hashing.HashSize, test.alg.Size())
+		assert.Equal(t, test.alg.Size(), test.alg.New().Size())
+
+		assert.Equal(t, test.empty, test.alg.Sum().ToHex())
+
+		sum := test.alg.Sum([]byte("Hello, "), []byte("World!"))
+		assert.Equal(t, test.expected, sum.ToHex())
+		assert.Equal(t, test.name+":"+test.expected, sum.String())
+		assert.Equal(t, test.alg, sum.Algorithm)
+
+		var hashSum hashing.HashSum
+		hashing.HashWith(test.alg, &hashSum, []byte("Hello, World!"))
+		assert.Equal(t, test.expected, hashSum.ToHex())
+
+		parsed, err := hashing.ParseAlgorithm(test.name)
+		assert.NoError(t, err)
+		assert.Equal(t, test.alg, parsed)
+	}
+}
+
+func TestDefaultAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	var zero hashing.Algorithm
+	assert.Equal(t, hashing.BLAKE2s, zero)
+	assert.Equal(t, hashing.BLAKE2s, hashing.Default)
+
+	var sum hashing.HashSum
+	hashing.Hash(&sum, []byte("Hello, World!"))
+	assert.Equal(t, hashing.Default.Sum([]byte("Hello, World!")).Digest, sum[:])
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	unknown := hashing.Algorithm(0xFF)
+	assert.False(t, unknown.Available())
+	assert.Equal(t, 0, unknown.Size())
+	assert.Equal(t, "unknown", unknown.String())
+	assert.Panics(t, func() { unknown.New() })
+
+	alg, err := hashing.ParseAlgorithm("sha3-256")
+	assert.NoError(t, err)
+	assert.Equal(t, hashing.SHA3_256, alg)
+
+	_, err = hashing.ParseAlgorithm("md5")
+	assert.Equal(t, hashing.ErrUnknownAlgorithm, err)
+}
+
+func TestSumEqual(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("data")
+
+	assert.True(t, hashing.SHA256.Sum(data).Equal(hashing.SHA256.Sum(data)))
+	assert.False(t, hashing.SHA256.Sum(data).Equal(hashing.SHA256.Sum(nil)))
+	assert.False(t, hashing.SHA256.Sum(data).Equal(hashing.BLAKE2s.Sum(data)))
+}
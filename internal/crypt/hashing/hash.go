@@ -20,23 +20,10 @@ func (h *HashSum) ToHex() string {
 
 // HashFunction ...
 func HashFunction() hash.Hash {
-	h, _ := blake2s.New256(nil)
-	return h
+	return Default.New()
 }
 
 // Hash ...
 func Hash(sum *HashSum, data ...[]byte) {
-	h := HashFunction()
-	defer h.Reset()
-
-	for _, set := range data {
-		h.Write(set)
-	}
-
-	if sum == nil {
-		return
-	}
-
-	h.Sum(sum[:0])
-	return
+	HashWith(Default, sum, data...)
 }
@@ -0,0 +1,89 @@
+package hashing
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+const (
+	// MACSize is the size of a MAC.
+	MACSize = blake2s.Size
+
+	// ShortMACSize is the size of a ShortMAC.
+	ShortMACSize = blake2s.Size128
+
+	// MaxMACKeySize is the largest key accepted by MAC and ShortMAC.
+	MaxMACKeySize = blake2s.Size
+)
+
+// ErrInvalidKeySize is returned for empty MAC keys or keys longer than
+// MaxMACKeySize.
+var ErrInvalidKeySize = errors.New("invalid mac key size")
+
+// MAC returns the keyed BLAKE2s-256 (RFC 7693) of the data. Keyed BLAKE2s is
+// a MAC on its own, at the cost of a single hash rather than the two of HMAC.
+func MAC(key []byte, data ...[]byte) (sum HashSum, err error) {
+	if len(key) == 0 || len(key) > MaxMACKeySize {
+		return sum, ErrInvalidKeySize
+	}
+
+	h, err := blake2s.New256(key)
+	if err != nil {
+		return sum, err
+	}
+
+	for _, set := range data {
+		h.Write(set)
+	}
+
+	h.Sum(sum[:0])
+	return
+}
+
+// ShortMAC returns the keyed BLAKE2s-128 (RFC 7693) of the data, for packet
+// authentication where a full MAC would waste space.
+func ShortMAC(key []byte, data ...[]byte) (sum [ShortMACSize]byte, err error) {
+	if len(key) == 0 || len(key) > MaxMACKeySize {
+		return sum, ErrInvalidKeySize
+	}
+
+	h, err := blake2s.New128(key)
+	if err != nil {
+		return sum, err
+	}
+
+	for _, set := range data {
+		h.Write(set)
+	}
+
+	h.Sum(sum[:0])
+	return
+}
+
+// VerifyMAC returns true if mac is the MAC or ShortMAC of the data, depending
+// on its size. The comparison is done in constant time.
+func VerifyMAC(mac, key []byte, data ...[]byte) bool {
+	switch len(mac) {
+	case MACSize:
+		sum, err := MAC(key, data...)
+		return err == nil && hmac.Equal(mac, sum[:])
+	case ShortMACSize:
+		sum, err := ShortMAC(key, data...)
+		return err == nil && hmac.Equal(mac, sum[:])
+	}
+
+	return false
+}
+
+// HashLabel hashes the data prefixed by the label, domain separating hashes
+// made for different purposes. The label is length prefixed so no label and
+// data pair can produce the input of another.
+func HashLabel(sum *HashSum, label string, data ...[]byte) {
+	var prefix [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(prefix[:], uint64(len(label)))
+
+	HashWith(Default, sum, append([][]byte{prefix[:size], []byte(label)}, data...)...)
+}
@@ -0,0 +1,93 @@
+package hashing_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+func testKey() []byte {
+	key := make([]byte, hashing.MaxMACKeySize)
+	for index := range key {
+		key[index] = byte(index)
+	}
+
+	return key
+}
+
+func TestMAC(t *testing.T) {
+	t.Parallel()
+
+	key := testKey()
+
+	sum, err := hashing.MAC(key, []byte("We attack "), []byte("at dawn."))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"1883720637f09442c1a40558f8db64370569f709438cdf8c1ee0f0fb1afad3a8",
+		sum.ToHex())
+
+	short, err := hashing.ShortMAC(key, []byte("We attack at dawn."))
+	assert.NoError(t, err)
+	assert.Equal(t, "72b660cb710b554bf18b75aa8624a584",
+		hex.EncodeToString(short[:]))
+
+	// a keyed hash differs from the plain hash
+	var plain hashing.HashSum
+	hashing.Hash(&plain, []byte("We attack at dawn."))
+	assert.NotEqual(t, plain, sum)
+
+	for _, invalid := range [][]byte{nil, make([]byte, hashing.MaxMACKeySize+1)} {
+		_, err = hashing.MAC(invalid, []byte("data"))
+		assert.Equal(t, hashing.ErrInvalidKeySize, err)
+		_, err = hashing.ShortMAC(invalid, []byte("data"))
+		assert.Equal(t, hashing.ErrInvalidKeySize, err)
+	}
+}
+
+func TestVerifyMAC(t *testing.T) {
+	t.Parallel()
+
+	key := testKey()
+	data := []byte("We attack at dawn.")
+
+	sum, err := hashing.MAC(key, data)
+	assert.NoError(t, err)
+	short, err := hashing.ShortMAC(key, data)
+	assert.NoError(t, err)
+
+	assert.True(t, hashing.VerifyMAC(sum[:], key, data))
+	assert.True(t, hashing.VerifyMAC(short[:], key, data))
+
+	assert.False(t, hashing.VerifyMAC(sum[:], key, []byte("We attack at night.")))
+	assert.False(t, hashing.VerifyMAC(short[:], key[1:], data))
+	assert.False(t, hashing.VerifyMAC(sum[:31], key, data))
+	assert.False(t, hashing.VerifyMAC(nil, key, data))
+	assert.False(t, hashing.VerifyMAC(sum[:], nil, data))
+
+	sum[0] ^= 0xFF
+	assert.False(t, hashing.VerifyMAC(sum[:], key, data))
+}
+
+func TestHashLabel(t *testing.T) {
+	t.Parallel()
+
+	var sum, other hashing.HashSum
+
+	hashing.HashLabel(&sum, "mac1-", []byte("We attack at dawn."))
+	assert.Equal(t,
+		"1ae2e43d48b875de91425cf4e73be7d4102a8b4d147bcf2fcfe19175fc2c05af",
+		sum.ToHex())
+
+	// moving bytes between the label and data changes the hash
+	hashing.HashLabel(&other, "mac1", []byte("-We attack at dawn."))
+	assert.NotEqual(t, sum, other)
+
+	hashing.HashLabel(&other, "mac2-", []byte("We attack at dawn."))
+	assert.NotEqual(t, sum, other)
+
+	hashing.Hash(&other, []byte("mac1-We attack at dawn."))
+	assert.NotEqual(t, sum, other)
+}
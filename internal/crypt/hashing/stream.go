@@ -0,0 +1,90 @@
+package hashing
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// DefaultBufferSize is the read buffer size used by HashReader when none is
+// set.
+const DefaultBufferSize = 64 * 1024
+
+// StreamOptions configure HashReader. The zero value hashes using the Default
+// algorithm and a DefaultBufferSize buffer, without progress reports.
+type StreamOptions struct {
+	// Algorithm is the hash algorithm to use.
+	Algorithm Algorithm
+
+	// BufferSize is the size of each read from the reader.
+	BufferSize int
+
+	// Progress, if set, is called after each read with the total number of
+	// bytes hashed so far.
+	Progress func(hashed int64)
+}
+
+// HashReader hashes everything read from r until io.EOF, writing the digest
+// to sum and returning the number of bytes hashed. Hashing stops with the
+// context error if ctx is done before the reader is drained, leaving sum
+// unchanged. If nil options are given, the zero StreamOptions are used.
+func HashReader(ctx context.Context, sum *HashSum, r io.Reader, opts *StreamOptions) (int64, error) {
+	if opts == nil {
+		opts = new(StreamOptions)
+	}
+
+	size := opts.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	if !opts.Algorithm.Available() || opts.Algorithm.Size() != HashSize {
+		return 0, ErrUnknownAlgorithm
+	}
+
+	var (
+		h      = opts.Algorithm.New()
+		buffer = make([]byte, size)
+		hashed int64
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return hashed, ctx.Err()
+		default:
+		}
+
+		n, err := r.Read(buffer)
+		if n > 0 {
+			h.Write(buffer[:n])
+			hashed += int64(n)
+
+			if opts.Progress != nil {
+				opts.Progress(hashed)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return hashed, err
+		}
+	}
+
+	h.Sum(sum[:0])
+
+	return hashed, nil
+}
+
+// HashFile hashes the contents of the file at path using HashReader.
+func HashFile(ctx context.Context, sum *HashSum, path string, opts *StreamOptions) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return HashReader(ctx, sum, file, opts)
+}
@@ -0,0 +1,140 @@
+package hashing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+func TestHashReader(t *testing.T) {
+	t.Parallel()
+
+	data := crypt.RandomBytes(100000)
+
+	var expected, sum hashing.HashSum
+	hashing.Hash(&expected, data)
+
+	n, err := hashing.HashReader(context.Background(), &sum,
+		bytes.NewReader(data), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, expected, sum)
+
+	// small, uneven reads
+	var progress []int64
+	n, err = hashing.HashReader(context.Background(), &sum,
+		iotest.HalfReader(bytes.NewReader(data)), &hashing.StreamOptions{
+			BufferSize: 4096,
+			Progress: func(hashed int64) {
+				progress = append(progress, hashed)
+			},
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, expected, sum)
+
+	assert.Len(t, progress, (len(data)+2047)/2048)
+	assert.Equal(t, int64(len(data)), progress[len(progress)-1])
+	for index := 1; index < len(progress); index++ {
+		assert.True(t, progress[index] > progress[index-1])
+	}
+
+	// other algorithms
+	n, err = hashing.HashReader(context.Background(), &sum,
+		bytes.NewReader(data), &hashing.StreamOptions{Algorithm: hashing.SHA256})
+	assert.NoError(t, err)
+	assert.Equal(t, hashing.SHA256.Sum(data).Digest, sum[:])
+
+	// empty input
+	n, err = hashing.HashReader(context.Background(), &sum,
+		bytes.NewReader(nil), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t,
+		"69217a3079908094e11121d042354a7c1f55b6482ca1a51e1b250dfd1ed0eef9",
+		sum.ToHex())
+}
+
+func TestHashReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	var sum hashing.HashSum
+
+	readErr := errors.New("read failed")
+	_, err := hashing.HashReader(context.Background(), &sum,
+		iotest.TimeoutReader(bytes.NewReader(make([]byte, 10))),
+		&hashing.StreamOptions{BufferSize: 4})
+	assert.Equal(t, iotest.ErrTimeout, err)
+
+	_, err = hashing.HashReader(context.Background(), &sum,
+		&failingReader{readErr}, nil)
+	assert.Equal(t, readErr, err)
+	assert.True(t, sum == hashing.HashSum{}, "sum written on error")
+
+	_, err = hashing.HashReader(context.Background(), &sum,
+		bytes.NewReader(nil), &hashing.StreamOptions{
+			Algorithm: hashing.Algorithm(0xFF),
+		})
+	assert.Equal(t, hashing.ErrUnknownAlgorithm, err)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestHashReaderCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var sum hashing.HashSum
+	n, err := hashing.HashReader(ctx, &sum,
+		bytes.NewReader(make([]byte, 1<<20)), &hashing.StreamOptions{
+			BufferSize: 1024,
+			Progress: func(hashed int64) {
+				if hashed == 4096 {
+					cancel()
+				}
+			},
+		})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(4096), n)
+}
+
+func TestHashFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "cryptor-hashing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := crypt.RandomBytes(10000)
+	path := filepath.Join(dir, "data")
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	var expected, sum hashing.HashSum
+	hashing.Hash(&expected, data)
+
+	n, err := hashing.HashFile(context.Background(), &sum, path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, expected, sum)
+
+	_, err = hashing.HashFile(context.Background(), &sum,
+		filepath.Join(dir, "missing"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package hashing
+
+import (
+	"cpl.li/go/cryptor/internal/crypt/codec"
+)
+
+// FromHex sets the sum from its hex encoding.
+func (h *HashSum) FromHex(src string) error {
+	return codec.Hex.Decode(h[:], src)
+}
+
+// Encode returns the sum using the given text encoding.
+func (h *HashSum) Encode(enc codec.Encoding) string {
+	return enc.Encode(h[:])
+}
+
+// Decode sets the sum from src using the given text encoding.
+func (h *HashSum) Decode(enc codec.Encoding, src string) error {
+	return enc.Decode(h[:], src)
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the sum as hex. It
+// has a value receiver so sums embedded by value are encoded too.
+func (h HashSum) MarshalText() ([]byte, error) {
+	return []byte(codec.Hex.Encode(h[:])), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting a sum in any
+// of the codec encodings.
+func (h *HashSum) UnmarshalText(text []byte) error {
+	return codec.DecodeAny(h[:], string(text))
+}
@@ -0,0 +1,42 @@
+package hashing_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+func TestHashSumText(t *testing.T) {
+	t.Parallel()
+
+	var sum, out hashing.HashSum
+	hashing.Hash(&sum, []byte("Hello, World!"))
+
+	assert.NoError(t, out.FromHex(sum.ToHex()))
+	assert.Equal(t, sum, out)
+	assert.Error(t, out.FromHex(sum.ToHex()[2:]))
+
+	for _, enc := range []codec.Encoding{
+		codec.Hex, codec.Base64, codec.Base64URL, codec.Base32, codec.Base58,
+	} {
+		out = hashing.HashSum{}
+		assert.NoError(t, out.Decode(enc, sum.Encode(enc)))
+		assert.Equal(t, sum, out, enc)
+
+		out = hashing.HashSum{}
+		assert.NoError(t, out.UnmarshalText([]byte(sum.Encode(enc))))
+		assert.Equal(t, sum, out, enc)
+	}
+
+	data, err := json.Marshal(map[string]hashing.HashSum{"sum": sum})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"sum":"`+sum.ToHex()+`"}`, string(data))
+
+	var decoded map[string]hashing.HashSum
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, sum, decoded["sum"])
+}
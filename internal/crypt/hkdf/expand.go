@@ -0,0 +1,115 @@
+package hkdf
+
+import (
+	"crypto/hmac"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/blake2s"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+// MaxOutput is the most keying material Expand can derive from a single
+// pseudorandom key and info.
+const MaxOutput = 255 * blake2s.Size
+
+var (
+	// ErrOutputTooLong is returned when more than MaxOutput bytes are
+	// requested.
+	ErrOutputTooLong = errors.New("hkdf output longer than 255 hash blocks")
+
+	// ErrInvalidLength is returned when a negative output length is
+	// requested.
+	ErrInvalidLength = errors.New("negative hkdf output length")
+)
+
+// Extract returns the pseudorandom key extracted from the input keying
+// material and the optional salt, as defined by RFC 5869. A nil salt is the
+// same as a salt of blake2s.Size zeros.
+func Extract(salt, ikm []byte) (prk [blake2s.Size]byte) {
+	return ExtractWith(hashing.Default, salt, ikm)
+}
+
+// ExtractWith is Extract using the given hash algorithm.
+func ExtractWith(alg hashing.Algorithm, salt, ikm []byte) (prk [blake2s.Size]byte) {
+	if salt == nil {
+		salt = make([]byte, blake2s.Size)
+	}
+
+	HMACWith(alg, &prk, salt, ikm)
+	return
+}
+
+// Expand returns length bytes of output keying material derived from the
+// pseudorandom key and the optional context info, as defined by RFC 5869.
+func Expand(prk, info []byte, length int) ([]byte, error) {
+	return ExpandWith(hashing.Default, prk, info, length)
+}
+
+// ExpandWith is Expand using the given hash algorithm.
+func ExpandWith(alg hashing.Algorithm, prk, info []byte, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, ErrInvalidLength
+	}
+	if length > MaxOutput {
+		return nil, ErrOutputTooLong
+	}
+
+	out := make([]byte, length)
+	if _, err := io.ReadFull(NewExpanderWith(alg, prk, info), out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+type expander struct {
+	mac     hash.Hash
+	info    []byte
+	counter byte
+	block   []byte
+	unread  []byte
+}
+
+// NewExpander returns a reader of the HKDF-Expand output keying material for
+// the pseudorandom key and context info. Reads past MaxOutput bytes fail with
+// ErrOutputTooLong.
+func NewExpander(prk, info []byte) io.Reader {
+	return NewExpanderWith(hashing.Default, prk, info)
+}
+
+// NewExpanderWith is NewExpander using the given hash algorithm.
+func NewExpanderWith(alg hashing.Algorithm, prk, info []byte) io.Reader {
+	return &expander{
+		mac:  hmac.New(alg.New, prk),
+		info: append([]byte(nil), info...),
+	}
+}
+
+func (e *expander) Read(p []byte) (int, error) {
+	read := 0
+
+	for read < len(p) {
+		if len(e.unread) == 0 {
+			if e.counter == 255 {
+				return read, ErrOutputTooLong
+			}
+			e.counter++
+
+			e.mac.Reset()
+			e.mac.Write(e.block)
+			e.mac.Write(e.info)
+			e.mac.Write([]byte{e.counter})
+			e.block = e.mac.Sum(e.block[:0])
+			e.unread = e.block
+		}
+
+		n := copy(p[read:], e.unread)
+		e.unread = e.unread[n:]
+		read += n
+	}
+
+	return read, nil
+}
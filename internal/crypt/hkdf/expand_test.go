@@ -0,0 +1,191 @@
+package hkdf_test
+
+import (
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xhkdf "golang.org/x/crypto/hkdf"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/hkdf"
+)
+
+func TestExtractExpand(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range testData {
+		key, _ := hex.DecodeString(test.key)
+		data, _ := hex.DecodeString(test.data)
+
+		prk := hkdf.Extract(key, data)
+		okm, err := hkdf.Expand(prk[:], nil, 96)
+		assert.NoError(t, err)
+
+		assert.Equal(t, test.t0+test.t1+test.t2, hex.EncodeToString(okm),
+			"unexpected HKDF derivation")
+
+		// shorter outputs are prefixes of longer ones
+		short, err := hkdf.Expand(prk[:], nil, 40)
+		assert.NoError(t, err)
+		assert.Equal(t, okm[:40], short)
+	}
+}
+
+// RFC 5869 test cases, with SHA-256 replaced by BLAKE2s.
+var rfcTests = []struct {
+	ikm, salt, info string
+	length          int
+	okm             string
+}{
+	{
+		ikm:    "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		salt:   "000102030405060708090a0b0c",
+		info:   "f0f1f2f3f4f5f6f7f8f9",
+		length: 42,
+		okm: "1472c31f2ff768c71b19f8803683ee3b13c1a5fb3ea59c0c3bf0" +
+			"d44a4a40dcd4329d9cd85bbe35a1b3e7",
+	},
+	{
+		ikm: "000102030405060708090a0b0c0d0e0f" +
+			"101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f" +
+			"303132333435363738393a3b3c3d3e3f" +
+			"404142434445464748494a4b4c4d4e4f",
+		salt: "606162636465666768696a6b6c6d6e6f" +
+			"707172737475767778797a7b7c7d7e7f" +
+			"808182838485868788898a8b8c8d8e8f" +
+			"909192939495969798999a9b9c9d9e9f" +
+			"a0a1a2a3a4a5a6a7a8a9aaabacadaeaf",
+		info: "b0b1b2b3b4b5b6b7b8b9babbbcbdbebf" +
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf" +
+			"d0d1d2d3d4d5d6d7d8d9dadbdcdddedf" +
+			"e0e1e2e3e4e5e6e7e8e9eaebecedeeef" +
+			"f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		length: 82,
+	},
+	{
+		ikm:    "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		length: 42,
+		okm: "064c0f0b9d9148a2e5ac797e5ef23d1b39b422f1ec37b57b4506" +
+			"5ff2b607527143b9b9f8ba59db392663",
+	},
+	{
+		ikm:    "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		info:   "f0f1f2f3f4f5f6f7f8f9",
+		length: hkdf.MaxOutput,
+	},
+}
+
+func TestRFC5869(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range rfcTests {
+		ikm, _ := hex.DecodeString(test.ikm)
+		info, _ := hex.DecodeString(test.info)
+
+		var salt []byte
+		if test.salt != "" {
+			salt, _ = hex.DecodeString(test.salt)
+		}
+
+		expected := make([]byte, test.length)
+		_, err := io.ReadFull(
+			xhkdf.New(hashing.HashFunction, ikm, salt, info), expected)
+		assert.NoError(t, err)
+
+		prk := hkdf.Extract(salt, ikm)
+		assert.Equal(t,
+			xhkdf.Extract(hashing.HashFunction, ikm, salt), prk[:],
+			"unexpected HKDF extract")
+
+		okm, err := hkdf.Expand(prk[:], info, test.length)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, okm, "unexpected HKDF expand")
+
+		if test.okm != "" {
+			assert.Equal(t, test.okm, hex.EncodeToString(okm),
+				"unexpected HKDF output keying material")
+		}
+	}
+}
+
+func TestExpander(t *testing.T) {
+	t.Parallel()
+
+	prk := hkdf.Extract([]byte("salt"), []byte("input"))
+	expected, err := hkdf.Expand(prk[:], []byte("info"), hkdf.MaxOutput)
+	assert.NoError(t, err)
+
+	// read in uneven chunks crossing block boundaries
+	r := hkdf.NewExpander(prk[:], []byte("info"))
+	var out []byte
+	for _, size := range []int{1, 31, 33, 64, 7, 100} {
+		buf := make([]byte, size)
+		n, err := r.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, size, n)
+		out = append(out, buf...)
+	}
+	assert.Equal(t, expected[:len(out)], out)
+
+	rest := make([]byte, hkdf.MaxOutput-len(out))
+	_, err = io.ReadFull(r, rest)
+	assert.NoError(t, err)
+	assert.Equal(t, expected[len(out):], rest)
+
+	n, err := r.Read(make([]byte, 1))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, hkdf.ErrOutputTooLong, err)
+
+	// different info, independent output
+	other, err := hkdf.Expand(prk[:], []byte("other"), 32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, expected[:32], other)
+
+	for _, test := range []struct {
+		length int
+		err    error
+	}{
+		{0, nil},
+		{hkdf.MaxOutput, nil},
+		{hkdf.MaxOutput + 1, hkdf.ErrOutputTooLong},
+		{-1, hkdf.ErrInvalidLength},
+		{-hkdf.MaxOutput - 1, hkdf.ErrInvalidLength},
+	} {
+		out, err := hkdf.Expand(prk[:], nil, test.length)
+		assert.Equal(t, test.err, err, test.length)
+		if test.err == nil {
+			assert.Len(t, out, test.length)
+		}
+	}
+}
+
+func TestExpandSHA256(t *testing.T) {
+	t.Parallel()
+
+	// RFC 5869 test case 1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	prk := hkdf.ExtractWith(hashing.SHA256, salt, ikm)
+	assert.Equal(t,
+		"077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+		hex.EncodeToString(prk[:]))
+
+	okm, err := hkdf.ExpandWith(hashing.SHA256, prk[:], info, 42)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db0"+
+			"2d56ecc4c5bf34007208d5b887185865",
+		hex.EncodeToString(okm))
+
+	// the fixed output HKDF follows the selected algorithm too
+	var t0, t1 [32]byte
+	hkdf.HKDFWith(hashing.SHA256, salt, ikm, &t0, &t1)
+	okm, err = hkdf.ExpandWith(hashing.SHA256, prk[:], nil, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, okm, append(t0[:], t1[:]...))
+}
@@ -12,7 +12,12 @@ import (
 // HMAC (hash-based message authentication code).
 // https://tools.ietf.org/html/rfc2104
 func HMAC(sum *[blake2s.Size]byte, key []byte, data ...[]byte) {
-	mac := hmac.New(hashing.HashFunction, key)
+	HMACWith(hashing.Default, sum, key, data...)
+}
+
+// HMACWith is HMAC using the given hash algorithm.
+func HMACWith(alg hashing.Algorithm, sum *[blake2s.Size]byte, key []byte, data ...[]byte) {
+	mac := hmac.New(alg.New, key)
 
 	for _, set := range data {
 		mac.Write(set)
@@ -24,17 +29,22 @@ func HMAC(sum *[blake2s.Size]byte, key []byte, data ...[]byte) {
 // HKDF (hash key derivation function).
 // https://tools.ietf.org/html/rfc5869
 func HKDF(key, data []byte, outkeys ...*[blake2s.Size]byte) {
+	HKDFWith(hashing.Default, key, data, outkeys...)
+}
+
+// HKDFWith is HKDF using the given hash algorithm.
+func HKDFWith(alg hashing.Algorithm, key, data []byte, outkeys ...*[blake2s.Size]byte) {
 	var localKey [blake2s.Size]byte
-	HMAC(&localKey, key, data)
+	HMACWith(alg, &localKey, key, data)
 	defer crypt.ZeroBytes(localKey[:])
 
 	for index, outkey := range outkeys {
 		iter := []byte{byte(index + 1)}
 
 		if index <= 0 {
-			HMAC(outkey, localKey[:], iter)
+			HMACWith(alg, outkey, localKey[:], iter)
 		} else {
-			HMAC(outkey, localKey[:], outkeys[index-1][:], iter)
+			HMACWith(alg, outkey, localKey[:], outkeys[index-1][:], iter)
 		}
 	}
 }
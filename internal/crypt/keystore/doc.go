@@ -0,0 +1 @@
+package keystore // import "cpl.li/go/cryptor/internal/crypt/keystore"
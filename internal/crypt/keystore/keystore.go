@@ -0,0 +1,249 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/pbkdf2"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	chacha "golang.org/x/crypto/chacha20poly1305"
+)
+
+// A keystore file holds a private key encrypted with ChaCha20-Poly1305 under
+// a key derived from a password. It is laid out as:
+//
+//	magic (8) | version (1) | params length (1) | kdf params |
+//	salt | nonce (12) | encrypted key (48)
+//
+// The KDF params are the pbkdf2.PasswordKDF encoding and set the salt size.
+// Everything before the encrypted key is authenticated as additional data, so
+// the KDF parameters can't be altered.
+//
+// Version 1 keystores, with a fixed pbkdf2 iterations (4) field in place of
+// the params and a 16 byte salt, can still be decrypted.
+const (
+	// Version is the keystore format version written by Encrypt.
+	Version = 2
+
+	// SaltSize is the salt size of version 1 keystores.
+	SaltSize = 16
+
+	encryptedSize = ppk.KeySize + 16
+	v1HeaderSize  = len(magic) + 1 + 1 + 4 + SaltSize + chacha.NonceSize
+)
+
+// KDF identifiers of version 1 keystores.
+const (
+	kdfPBKDF2 byte = iota + 1
+)
+
+const magic = "CRYPTORK"
+
+// DefaultKDF is used by Encrypt and Save when no KDF is given.
+var DefaultKDF pbkdf2.PasswordKDF = pbkdf2.DefaultPBKDF2
+
+var (
+	// ErrInvalidKeystore is returned when the data is not a keystore.
+	ErrInvalidKeystore = errors.New("invalid keystore")
+
+	// ErrUnsupportedKeystore is returned for keystores using an unknown
+	// version or KDF parameters.
+	ErrUnsupportedKeystore = errors.New("unsupported keystore version or parameters")
+
+	// ErrWrongPassword is returned when the keystore can't be decrypted.
+	ErrWrongPassword = errors.New("wrong password or corrupted keystore")
+)
+
+// Encrypt returns the keystore encoding of the private key, encrypted using
+// the password under a key derived by the KDF. If a nil KDF is given,
+// DefaultKDF is used.
+func Encrypt(sk *ppk.PrivateKey, password []byte, kdf pbkdf2.PasswordKDF) ([]byte, error) {
+	if kdf == nil {
+		kdf = DefaultKDF
+	}
+
+	params := kdf.String()
+	if len(params) > 0xFF {
+		return nil, ErrUnsupportedKeystore
+	}
+
+	salt, err := kdf.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kdf.Derive(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer crypt.ZeroBytes(key)
+
+	cipher, err := chacha.New(key)
+	if err != nil {
+		return nil, ErrUnsupportedKeystore
+	}
+
+	headerSize := len(magic) + 2 + len(params) + len(salt) + chacha.NonceSize
+	data := make([]byte, 0, headerSize+encryptedSize)
+
+	data = append(data, magic...)
+	data = append(data, Version, byte(len(params)))
+	data = append(data, params...)
+	data = append(data, salt...)
+	data = data[:headerSize]
+
+	nonce := data[headerSize-chacha.NonceSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return cipher.Seal(data, nonce, sk[:], data), nil
+}
+
+// Decrypt decodes the keystore and decrypts the private key using the
+// password.
+func Decrypt(data, password []byte, sk *ppk.PrivateKey) error {
+	if len(data) < len(magic)+1 || !bytes.HasPrefix(data, []byte(magic)) {
+		return ErrInvalidKeystore
+	}
+
+	var (
+		kdf    pbkdf2.PasswordKDF
+		offset int
+		err    error
+	)
+
+	switch data[len(magic)] {
+	case 1:
+		kdf, offset, err = decodeV1(data)
+	case Version:
+		kdf, offset, err = decodeV2(data)
+	default:
+		return ErrUnsupportedKeystore
+	}
+	if err != nil {
+		return err
+	}
+
+	headerSize := len(data) - encryptedSize
+	if headerSize-chacha.NonceSize < offset {
+		return ErrInvalidKeystore
+	}
+
+	salt := data[offset : headerSize-chacha.NonceSize]
+
+	key, err := kdf.Derive(password, salt)
+	if err == pbkdf2.ErrInvalidSalt {
+		return ErrInvalidKeystore
+	}
+	if err != nil {
+		return ErrUnsupportedKeystore
+	}
+	defer crypt.ZeroBytes(key)
+
+	cipher, err := chacha.New(key)
+	if err != nil {
+		return ErrUnsupportedKeystore
+	}
+
+	plaintext, err := cipher.Open(nil, data[headerSize-chacha.NonceSize:headerSize],
+		data[headerSize:], data[:headerSize])
+	if err != nil {
+		return ErrWrongPassword
+	}
+	defer crypt.ZeroBytes(plaintext)
+
+	copy(sk[:], plaintext)
+
+	return nil
+}
+
+// decodeV1 returns the KDF of a version 1 keystore and the offset of its
+// salt.
+func decodeV1(data []byte) (pbkdf2.PasswordKDF, int, error) {
+	if len(data) != v1HeaderSize+encryptedSize {
+		return nil, 0, ErrInvalidKeystore
+	}
+
+	offset := len(magic) + 1
+	if data[offset] != kdfPBKDF2 {
+		return nil, 0, ErrUnsupportedKeystore
+	}
+	offset++
+
+	if binary.BigEndian.Uint32(data[offset:]) != pbkdf2.Iterations {
+		return nil, 0, ErrUnsupportedKeystore
+	}
+	offset += 4
+
+	return pbkdf2.PBKDF2{
+		Iterations: pbkdf2.Iterations,
+		SaltSize:   SaltSize,
+		KeySize:    chacha.KeySize,
+	}, offset, nil
+}
+
+// decodeV2 parses the KDF params of a version 2 keystore and returns the
+// offset of its salt.
+func decodeV2(data []byte) (pbkdf2.PasswordKDF, int, error) {
+	offset := len(magic) + 1
+	if len(data) < offset+1 {
+		return nil, 0, ErrInvalidKeystore
+	}
+
+	size := int(data[offset])
+	offset++
+	if len(data) < offset+size {
+		return nil, 0, ErrInvalidKeystore
+	}
+
+	kdf, err := pbkdf2.Parse(string(data[offset : offset+size]))
+	if err != nil {
+		return nil, 0, ErrUnsupportedKeystore
+	}
+	offset += size
+
+	return kdf, offset, nil
+}
+
+// Save encrypts the private key using the password and KDF and writes the
+// keystore to a new file at path, readable only by its owner. An existing file
+// is never replaced, the error then satisfies os.IsExist.
+func Save(path string, sk *ppk.PrivateKey, password []byte, kdf pbkdf2.PasswordKDF) error {
+	data, err := Encrypt(sk, password, kdf)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return err
+}
+
+// Load reads the keystore file at path and decrypts the private key using the
+// password.
+func Load(path string, password []byte, sk *ppk.PrivateKey) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return Decrypt(data, password, sk)
+}
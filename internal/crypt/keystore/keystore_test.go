@@ -0,0 +1,211 @@
+package keystore_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/keystore"
+	"cpl.li/go/cryptor/internal/crypt/pbkdf2"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	chacha "golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	password = []byte("testing")
+
+	testKDF = pbkdf2.PBKDF2{Iterations: 1024, SaltSize: 16, KeySize: 32}
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	var sk, out ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	data, err := keystore.Encrypt(&sk, password, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), keystore.DefaultKDF.String())
+	assert.NotContains(t, string(data), string(sk[:]))
+
+	assert.NoError(t, keystore.Decrypt(data, password, &out))
+	assert.True(t, sk.Equals(out))
+
+	// same key and password, different salt and nonce
+	other, err := keystore.Encrypt(&sk, password, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, data, other)
+
+	assert.Equal(t, keystore.ErrWrongPassword,
+		keystore.Decrypt(data, []byte("wrong"), &out))
+}
+
+func TestEncryptKDF(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	for _, kdf := range []pbkdf2.PasswordKDF{
+		testKDF,
+		pbkdf2.PBKDF2{Iterations: 1, SaltSize: 32, KeySize: 32},
+		pbkdf2.Argon2id{Time: 1, Memory: 64, Threads: 1, SaltSize: 8, KeySize: 32},
+	} {
+		var out ppk.PrivateKey
+
+		data, err := keystore.Encrypt(&sk, password, kdf)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), kdf.String())
+
+		assert.NoError(t, keystore.Decrypt(data, password, &out))
+		assert.True(t, sk.Equals(out))
+	}
+
+	// keys of the wrong size can't be used with ChaCha20-Poly1305
+	_, err := keystore.Encrypt(&sk, password,
+		pbkdf2.PBKDF2{Iterations: 1, SaltSize: 16, KeySize: 16})
+	assert.Equal(t, keystore.ErrUnsupportedKeystore, err)
+	_, err = keystore.Encrypt(&sk, password, pbkdf2.PBKDF2{})
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+}
+
+func TestDecryptVersion1(t *testing.T) {
+	t.Parallel()
+
+	var sk, out ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	var header bytes.Buffer
+	header.WriteString("CRYPTORK")
+	header.Write([]byte{1, 1})
+	binary.Write(&header, binary.BigEndian, uint32(pbkdf2.Iterations))
+	salt := bytes.Repeat([]byte{0x5A}, keystore.SaltSize)
+	header.Write(salt)
+	nonce := make([]byte, chacha.NonceSize)
+	header.Write(nonce)
+
+	key := pbkdf2.Key(password, salt)
+	cipher, err := chacha.New(key[:])
+	assert.NoError(t, err)
+	data := cipher.Seal(header.Bytes(), nonce, sk[:], header.Bytes())
+
+	assert.NoError(t, keystore.Decrypt(data, password, &out))
+	assert.True(t, sk.Equals(out))
+
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(data[:len(data)-1], password, &out))
+
+	data[13] ^= 0xFF
+	assert.Equal(t, keystore.ErrUnsupportedKeystore,
+		keystore.Decrypt(data, password, &out))
+}
+
+func TestDecryptLegacyID(t *testing.T) {
+	t.Parallel()
+
+	var sk, out ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	params := "pbkdf2-blake2s$i=1024,s=16,k=32"
+
+	var header bytes.Buffer
+	header.WriteString("CRYPTORK")
+	header.Write([]byte{keystore.Version, byte(len(params))})
+	header.WriteString(params)
+	salt := bytes.Repeat([]byte{0x5A}, 16)
+	header.Write(salt)
+	nonce := make([]byte, chacha.NonceSize)
+	header.Write(nonce)
+
+	key, err := testKDF.Derive(password, salt)
+	assert.NoError(t, err)
+	cipher, err := chacha.New(key)
+	assert.NoError(t, err)
+	data := cipher.Seal(header.Bytes(), nonce, sk[:], header.Bytes())
+
+	assert.NoError(t, keystore.Decrypt(data, password, &out))
+	assert.True(t, sk.Equals(out))
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	data, err := keystore.Encrypt(&sk, password, testKDF)
+	assert.NoError(t, err)
+
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(nil, password, &sk))
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(data[1:], password, &sk))
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(data[:len(data)-1], password, &sk))
+
+	tamper := func(index int) []byte {
+		modified := append([]byte(nil), data...)
+		modified[index] ^= 0xFF
+		return modified
+	}
+
+	params := 10 + len(testKDF.String())
+
+	// magic
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(tamper(0), password, &sk))
+	// version
+	assert.Equal(t, keystore.ErrUnsupportedKeystore,
+		keystore.Decrypt(tamper(8), password, &sk))
+	// params length
+	assert.Equal(t, keystore.ErrInvalidKeystore,
+		keystore.Decrypt(tamper(9), password, &sk))
+	// params
+	for _, index := range []int{10, 20, params - 1} {
+		assert.Equal(t, keystore.ErrUnsupportedKeystore,
+			keystore.Decrypt(tamper(index), password, &sk))
+	}
+	// salt, nonce and ciphertext
+	for _, index := range []int{params, params + 16, len(data) - 1} {
+		assert.Equal(t, keystore.ErrWrongPassword,
+			keystore.Decrypt(tamper(index), password, &sk))
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "cryptor-keystore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var sk, out ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	path := filepath.Join(dir, "node.key")
+	assert.NoError(t, keystore.Save(path, &sk, password, testKDF))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.NoError(t, keystore.Load(path, password, &out))
+	assert.True(t, sk.Equals(out))
+
+	assert.Error(t, keystore.Load(filepath.Join(dir, "missing"), password, &out))
+
+	// an existing keystore is never replaced
+	var other ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&other))
+	err = keystore.Save(path, &other, password, testKDF)
+	assert.True(t, os.IsExist(err), err)
+
+	assert.NoError(t, keystore.Load(path, password, &out))
+	assert.True(t, sk.Equals(out))
+}
@@ -0,0 +1 @@
+package merkle // import "cpl.li/go/cryptor/internal/crypt/merkle"
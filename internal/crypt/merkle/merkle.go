@@ -0,0 +1,217 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+)
+
+// DefaultChunkSize is the chunk size used by Build when none is given.
+const DefaultChunkSize = 64 * 1024
+
+// Domain separation prefixes, so a leaf can never be passed off as an interior
+// node or the other way around.
+const (
+	leafPrefix byte = 0x00
+	nodePrefix byte = 0x01
+	rootPrefix byte = 0x02
+)
+
+var (
+	// ErrEmptyTree is returned when building a tree without any leaves.
+	ErrEmptyTree = errors.New("merkle tree without leaves")
+
+	// ErrIndexOutOfRange is returned when requesting the proof of a leaf
+	// which is not part of the tree.
+	ErrIndexOutOfRange = errors.New("leaf index out of range")
+)
+
+// LeafHash writes the hash of a leaf holding the chunk to sum.
+func LeafHash(sum *hashing.HashSum, chunk []byte) {
+	hashing.Hash(sum, []byte{leafPrefix}, chunk)
+}
+
+// NodeHash writes the hash of the interior node with the given children to
+// sum.
+func NodeHash(sum, left, right *hashing.HashSum) {
+	hashing.Hash(sum, []byte{nodePrefix}, left[:], right[:])
+}
+
+// RootHash writes the root hash of a tree with the given number of leaves and
+// top node to sum. Committing to the number of leaves fixes the shape of the
+// tree, so a leaf can't be proven at another position.
+func RootHash(sum, top *hashing.HashSum, leaves int) {
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], uint64(leaves))
+
+	hashing.Hash(sum, []byte{rootPrefix}, count[:], top[:])
+}
+
+// Tree is a binary Merkle tree over a list of leaf hashes. A node without a
+// sibling is promoted to the next level unchanged, so no leaf is ever hashed
+// twice. The root hash covers the top node and the number of leaves.
+type Tree struct {
+	// levels[0] holds the leaves and the last level holds the top node.
+	levels [][]hashing.HashSum
+	root   hashing.HashSum
+}
+
+// Build splits everything read from r into chunks of chunkSize bytes, the last
+// chunk possibly shorter, and returns the tree over their leaf hashes. Empty
+// input results in a tree with a single empty chunk. If chunkSize is not
+// positive, DefaultChunkSize is used.
+func Build(r io.Reader, chunkSize int) (*Tree, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	var (
+		chunk  = make([]byte, chunkSize)
+		leaves []hashing.HashSum
+	)
+
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF && len(leaves) > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		var leaf hashing.HashSum
+		LeafHash(&leaf, chunk[:n])
+		leaves = append(leaves, leaf)
+
+		if err != nil {
+			break
+		}
+	}
+
+	return New(leaves)
+}
+
+// New returns the tree over the given leaf hashes.
+func New(leaves []hashing.HashSum) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmptyTree
+	}
+
+	level := append([]hashing.HashSum(nil), leaves...)
+	tree := &Tree{levels: [][]hashing.HashSum{level}}
+
+	for len(level) > 1 {
+		next := make([]hashing.HashSum, (len(level)+1)/2)
+
+		for index := range next {
+			left := 2 * index
+			if left+1 == len(level) {
+				next[index] = level[left]
+				continue
+			}
+
+			NodeHash(&next[index], &level[left], &level[left+1])
+		}
+
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+
+	RootHash(&tree.root, &level[0], len(leaves))
+
+	return tree, nil
+}
+
+// Root returns the root hash of the tree.
+func (t *Tree) Root() hashing.HashSum {
+	return t.root
+}
+
+// Leaves returns the number of leaves of the tree.
+func (t *Tree) Leaves() int {
+	return len(t.levels[0])
+}
+
+// Proof returns the inclusion proof of the leaf at index.
+func (t *Tree) Proof(index int) (*Proof, error) {
+	if index < 0 || index >= t.Leaves() {
+		return nil, ErrIndexOutOfRange
+	}
+
+	proof := &Proof{
+		Index:  index,
+		Leaves: t.Leaves(),
+	}
+
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Proof is the inclusion proof of a leaf, holding the sibling hashes on the
+// path from the leaf to the root, leaf first. Index and Leaves are untrusted,
+// the root only matches if both are those of the tree.
+type Proof struct {
+	Index    int
+	Leaves   int
+	Siblings []hashing.HashSum
+}
+
+// Verify returns true if the chunk is the leaf at the proof index of the tree
+// with the given root.
+func (p *Proof) Verify(root *hashing.HashSum, chunk []byte) bool {
+	var leaf hashing.HashSum
+	LeafHash(&leaf, chunk)
+
+	return p.VerifyLeaf(root, &leaf)
+}
+
+// VerifyLeaf returns true if the leaf hash is the leaf at the proof index of
+// the tree with the given root.
+func (p *Proof) VerifyLeaf(root, leaf *hashing.HashSum) bool {
+	if p.Index < 0 || p.Index >= p.Leaves {
+		return false
+	}
+
+	var (
+		sum      = *leaf
+		index    = p.Index
+		width    = p.Leaves
+		siblings = p.Siblings
+	)
+
+	for width > 1 {
+		if index^1 < width {
+			if len(siblings) == 0 {
+				return false
+			}
+
+			if index&1 == 0 {
+				NodeHash(&sum, &sum, &siblings[0])
+			} else {
+				NodeHash(&sum, &siblings[0], &sum)
+			}
+			siblings = siblings[1:]
+		}
+
+		index /= 2
+		width = (width + 1) / 2
+	}
+
+	if len(siblings) != 0 {
+		return false
+	}
+
+	RootHash(&sum, &sum, p.Leaves)
+
+	return sum == *root
+}
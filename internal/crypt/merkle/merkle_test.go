@@ -0,0 +1,175 @@
+package merkle_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/merkle"
+)
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	tree, err := merkle.Build(bytes.NewReader([]byte("abc")), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Leaves())
+
+	root := tree.Root()
+	assert.Equal(t,
+		"95bdbb0f61a18e41c2de8a3195b9c1acb5625b91fd601108cd4d664ddaceed7c",
+		root.ToHex())
+
+	// empty input is a single empty chunk
+	tree, err = merkle.Build(bytes.NewReader(nil), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tree.Leaves())
+	root = tree.Root()
+	assert.Equal(t,
+		"9a00ce3a72a080c98fca128a26452a4163815809f834408d9919fc1f48e6415f",
+		root.ToHex())
+
+	// exact multiples of the chunk size don't add an empty chunk
+	tree, err = merkle.Build(bytes.NewReader(make([]byte, 64)), 16)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, tree.Leaves())
+
+	tree, err = merkle.Build(bytes.NewReader(make([]byte, 65)), 16)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, tree.Leaves())
+
+	_, err = merkle.New(nil)
+	assert.Equal(t, merkle.ErrEmptyTree, err)
+}
+
+func TestDomainSeparation(t *testing.T) {
+	t.Parallel()
+
+	var left, right, node, leaf hashing.HashSum
+	merkle.LeafHash(&left, []byte("left"))
+	merkle.LeafHash(&right, []byte("right"))
+	merkle.NodeHash(&node, &left, &right)
+
+	// a leaf holding the concatenated children does not hash to the node
+	merkle.LeafHash(&leaf, append(left[:], right[:]...))
+	assert.NotEqual(t, node, leaf)
+
+	// the root commits to the top node and the number of leaves
+	tree, err := merkle.New([]hashing.HashSum{left, right})
+	assert.NoError(t, err)
+	var root hashing.HashSum
+	merkle.RootHash(&root, &node, 2)
+	assert.Equal(t, root, tree.Root())
+	merkle.RootHash(&root, &node, 3)
+	assert.NotEqual(t, root, tree.Root())
+
+	// an interior node can't be proven as a leaf
+	single, err := merkle.New([]hashing.HashSum{node})
+	assert.NoError(t, err)
+	proof, err := single.Proof(0)
+	assert.NoError(t, err)
+	root = tree.Root()
+	assert.False(t, proof.Verify(&root, append(left[:], right[:]...)))
+}
+
+func TestProof(t *testing.T) {
+	t.Parallel()
+
+	const chunkSize = 32
+
+	for leaves := 1; leaves <= 33; leaves++ {
+		data := crypt.RandomBytes(uint(leaves*chunkSize - leaves%2))
+
+		tree, err := merkle.Build(bytes.NewReader(data), chunkSize)
+		assert.NoError(t, err)
+		assert.Equal(t, leaves, tree.Leaves())
+
+		root := tree.Root()
+
+		for index := 0; index < leaves; index++ {
+			end := (index + 1) * chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			chunk := data[index*chunkSize : end]
+
+			proof, err := tree.Proof(index)
+			assert.NoError(t, err)
+			assert.True(t, proof.Verify(&root, chunk), "leaves %d index %d", leaves, index)
+
+			// wrong chunk
+			assert.False(t, proof.Verify(&root, chunk[1:]))
+
+			// wrong position
+			if leaves > 1 {
+				moved := *proof
+				moved.Index = (index + 1) % leaves
+				assert.False(t, moved.Verify(&root, chunk))
+			}
+
+			// tampered siblings
+			if len(proof.Siblings) > 0 {
+				tampered := *proof
+				tampered.Siblings = append([]hashing.HashSum(nil), proof.Siblings...)
+				tampered.Siblings[0][0] ^= 0xFF
+				assert.False(t, tampered.Verify(&root, chunk))
+
+				short := *proof
+				short.Siblings = proof.Siblings[1:]
+				assert.False(t, short.Verify(&root, chunk))
+			}
+
+			long := *proof
+			long.Siblings = append(append([]hashing.HashSum(nil), proof.Siblings...), root)
+			assert.False(t, long.Verify(&root, chunk))
+		}
+	}
+}
+
+func TestProofInvalid(t *testing.T) {
+	t.Parallel()
+
+	tree, err := merkle.Build(bytes.NewReader([]byte("abcde")), 1)
+	assert.NoError(t, err)
+
+	_, err = tree.Proof(-1)
+	assert.Equal(t, merkle.ErrIndexOutOfRange, err)
+	_, err = tree.Proof(5)
+	assert.Equal(t, merkle.ErrIndexOutOfRange, err)
+
+	proof, err := tree.Proof(4)
+	assert.NoError(t, err)
+
+	root := tree.Root()
+	assert.True(t, proof.Verify(&root, []byte("e")))
+
+	other := tree.Root()
+	other[0] ^= 0xFF
+	assert.False(t, proof.Verify(&other, []byte("e")))
+
+	proof.Leaves = 4
+	assert.False(t, proof.Verify(&root, []byte("e")))
+	proof.Leaves = 0
+	assert.False(t, proof.Verify(&root, []byte("e")))
+}
+
+func TestProofForgedPosition(t *testing.T) {
+	t.Parallel()
+
+	tree, err := merkle.Build(bytes.NewReader([]byte("abc")), 1)
+	assert.NoError(t, err)
+	root := tree.Root()
+
+	proof, err := tree.Proof(2)
+	assert.NoError(t, err)
+	assert.True(t, proof.Verify(&root, []byte("c")))
+
+	// the last leaf is promoted, so its path matches leaf 1 of a tree with
+	// two leaves
+	proof.Leaves = 2
+	proof.Index = 1
+	assert.False(t, proof.Verify(&root, []byte("c")))
+}
@@ -119,6 +119,17 @@ func TestEntropyFromMnemonic(t *testing.T) {
 	}
 }
 
+func TestWord(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "abandon", mwords.Word(0))
+	assert.Equal(t, "zoo", mwords.Word(mwords.Count-1))
+
+	for index := 0; index < mwords.Count; index++ {
+		assert.True(t, mwords.IsValidWord(mwords.Word(index)))
+	}
+}
+
 func TestEntropyFromMnemonicInvalidChecksum(t *testing.T) {
 	t.Parallel()
 
@@ -9,6 +9,12 @@ func init() {
 	}
 }
 
+// Word returns the word at the given index of the word list. The index must
+// be less than Count.
+func Word(index int) string {
+	return mnemonicWords[index]
+}
+
 func IsValidWord(word string) bool {
 	_, ok := mnemonicLookup[word]
 	return ok
@@ -0,0 +1,316 @@
+package pbkdf2
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// KDF identifiers, the first field of an encoded PasswordKDF. PBKDF2 using a
+// hash algorithm other than BLAKE2s is identified by the lower case algorithm
+// name following the "cryptor-pbkdf2-" prefix.
+const (
+	IDPBKDF2   = idPBKDF2Prefix + "blake2s"
+	IDArgon2id = "argon2id"
+
+	idPBKDF2Prefix = "cryptor-pbkdf2-"
+
+	// idPBKDF2Legacy identified PBKDF2 with BLAKE2s before other hash
+	// algorithms were supported, and is still accepted by Parse.
+	idPBKDF2Legacy = "pbkdf2-blake2s"
+)
+
+const (
+	// MinSaltSize is the smallest salt accepted by a PasswordKDF.
+	MinSaltSize = 8
+
+	// MinKeySize is the smallest key a PasswordKDF can derive.
+	MinKeySize = 16
+
+	// MaxIterations is the largest PBKDF2 iteration count accepted, bounding
+	// the work done for an untrusted encoding.
+	MaxIterations = 1 << 22
+
+	// MaxArgon2Time, MaxArgon2Memory (in KiB) and MaxArgon2Threads are the
+	// largest Argon2id costs accepted, bounding the time and memory used for
+	// an untrusted encoding.
+	MaxArgon2Time    = 8
+	MaxArgon2Memory  = 1 << 20
+	MaxArgon2Threads = 16
+
+	maxSize = 1024
+)
+
+var (
+	// ErrInvalidParams is returned for out of range or malformed KDF
+	// parameters.
+	ErrInvalidParams = errors.New("invalid kdf parameters")
+
+	// ErrUnknownKDF is returned when parsing an unknown KDF identifier.
+	ErrUnknownKDF = errors.New("unknown kdf")
+
+	// ErrInvalidSalt is returned when the salt size does not match the
+	// parameters.
+	ErrInvalidSalt = errors.New("invalid salt size")
+)
+
+// PasswordKDF derives keys from passwords using tunable cost parameters. The
+// String encoding of a PasswordKDF describes its parameters and is turned
+// back into an identical PasswordKDF by Parse.
+type PasswordKDF interface {
+	// ID returns the KDF identifier.
+	ID() string
+
+	// String returns the encoded KDF parameters.
+	String() string
+
+	// NewSalt returns a random salt of the size set by the parameters.
+	NewSalt() ([]byte, error)
+
+	// Derive returns the key derived from the password and salt.
+	Derive(password, salt []byte) ([]byte, error)
+}
+
+// PBKDF2 is a PasswordKDF using pbkdf2 with HMAC over the Hash algorithm,
+// BLAKE2s unless set.
+type PBKDF2 struct {
+	Iterations int
+	SaltSize   int
+	KeySize    int
+	Hash       hashing.Algorithm
+}
+
+// Argon2id is a PasswordKDF using Argon2id. Memory is given in KiB.
+type Argon2id struct {
+	Time     uint32
+	Memory   uint32
+	Threads  uint8
+	SaltSize int
+	KeySize  int
+}
+
+var (
+	// DefaultPBKDF2 derives the same size of key as Key, with a random salt.
+	DefaultPBKDF2 = PBKDF2{
+		Iterations: Iterations,
+		SaltSize:   16,
+		KeySize:    ppk.KeySize,
+	}
+
+	// DefaultArgon2id uses 64 MiB of memory, suited to desktop class
+	// devices. Lower Memory and Time for constrained ones.
+	DefaultArgon2id = Argon2id{
+		Time:     3,
+		Memory:   64 * 1024,
+		Threads:  4,
+		SaltSize: 16,
+		KeySize:  ppk.KeySize,
+	}
+)
+
+// ID implements PasswordKDF.
+func (p PBKDF2) ID() string {
+	return idPBKDF2Prefix + strings.ToLower(p.Hash.String())
+}
+
+// String implements PasswordKDF, e.g.
+// "cryptor-pbkdf2-blake2s$i=131072,s=16,k=32".
+func (p PBKDF2) String() string {
+	return fmt.Sprintf("%s$i=%d,s=%d,k=%d",
+		p.ID(), p.Iterations, p.SaltSize, p.KeySize)
+}
+
+// Validate returns ErrInvalidParams if any parameter is out of range.
+func (p PBKDF2) Validate() error {
+	if p.Iterations < 1 || p.Iterations > MaxIterations || !p.Hash.Available() ||
+		!validSizes(p.SaltSize, p.KeySize) {
+		return ErrInvalidParams
+	}
+
+	return nil
+}
+
+// NewSalt implements PasswordKDF.
+func (p PBKDF2) NewSalt() ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newSalt(p.SaltSize)
+}
+
+// Derive implements PasswordKDF.
+func (p PBKDF2) Derive(password, salt []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) != p.SaltSize {
+		return nil, ErrInvalidSalt
+	}
+
+	return pbkdf2.Key(password, salt, p.Iterations, p.KeySize,
+		p.Hash.New), nil
+}
+
+// ID implements PasswordKDF.
+func (a Argon2id) ID() string {
+	return IDArgon2id
+}
+
+// String implements PasswordKDF, e.g.
+// "argon2id$v=19,t=3,m=65536,p=4,s=16,k=32".
+func (a Argon2id) String() string {
+	return fmt.Sprintf("%s$v=%d,t=%d,m=%d,p=%d,s=%d,k=%d",
+		IDArgon2id, argon2.Version, a.Time, a.Memory, a.Threads,
+		a.SaltSize, a.KeySize)
+}
+
+// Validate returns ErrInvalidParams if any parameter is out of range.
+func (a Argon2id) Validate() error {
+	if a.Time < 1 || a.Time > MaxArgon2Time ||
+		a.Threads < 1 || a.Threads > MaxArgon2Threads ||
+		a.Memory < 8*uint32(a.Threads) || a.Memory > MaxArgon2Memory ||
+		!validSizes(a.SaltSize, a.KeySize) {
+		return ErrInvalidParams
+	}
+
+	return nil
+}
+
+// NewSalt implements PasswordKDF.
+func (a Argon2id) NewSalt() ([]byte, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newSalt(a.SaltSize)
+}
+
+// Derive implements PasswordKDF.
+func (a Argon2id) Derive(password, salt []byte) ([]byte, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) != a.SaltSize {
+		return nil, ErrInvalidSalt
+	}
+
+	return argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads,
+		uint32(a.KeySize)), nil
+}
+
+// Parse returns the PasswordKDF described by the output of its String method.
+// The "pbkdf2-blake2s" identifier of earlier encodings is also accepted.
+func Parse(encoded string) (PasswordKDF, error) {
+	sep := strings.IndexByte(encoded, '$')
+	if sep < 0 {
+		return nil, ErrInvalidParams
+	}
+
+	id := encoded[:sep]
+	if id == idPBKDF2Legacy {
+		id = IDPBKDF2
+	}
+
+	if strings.HasPrefix(id, idPBKDF2Prefix) {
+		alg, err := hashing.ParseAlgorithm(id[len(idPBKDF2Prefix):])
+		if err != nil || id != strings.ToLower(id) {
+			return nil, ErrUnknownKDF
+		}
+
+		values, err := parseParams(encoded[sep+1:], []string{"i", "s", "k"})
+		if err != nil {
+			return nil, err
+		}
+
+		p := PBKDF2{
+			Iterations: int(values[0]),
+			SaltSize:   int(values[1]),
+			KeySize:    int(values[2]),
+			Hash:       alg,
+		}
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+
+		return p, nil
+	}
+
+	if id != IDArgon2id {
+		return nil, ErrUnknownKDF
+	}
+
+	values, err := parseParams(encoded[sep+1:],
+		[]string{"v", "t", "m", "p", "s", "k"})
+	if err != nil {
+		return nil, err
+	}
+
+	if values[0] != argon2.Version || values[3] > 0xFF {
+		return nil, ErrInvalidParams
+	}
+
+	a := Argon2id{
+		Time:     values[1],
+		Memory:   values[2],
+		Threads:  uint8(values[3]),
+		SaltSize: int(values[4]),
+		KeySize:  int(values[5]),
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// parseParams parses a list of name=value pairs with the given names, in
+// order, returning the values.
+func parseParams(params string, names []string) ([]uint32, error) {
+	fields := strings.Split(params, ",")
+	if len(fields) != len(names) {
+		return nil, ErrInvalidParams
+	}
+
+	values := make([]uint32, len(names))
+	for i, field := range fields {
+		if !strings.HasPrefix(field, names[i]+"=") {
+			return nil, ErrInvalidParams
+		}
+
+		digits := field[len(names[i])+1:]
+		if len(digits) > 1 && digits[0] == '0' {
+			return nil, ErrInvalidParams
+		}
+
+		value, err := strconv.ParseUint(digits, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+		values[i] = uint32(value)
+	}
+
+	return values, nil
+}
+
+func validSizes(saltSize, keySize int) bool {
+	return saltSize >= MinSaltSize && saltSize <= maxSize &&
+		keySize >= MinKeySize && keySize <= maxSize
+}
+
+func newSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
@@ -0,0 +1,218 @@
+package pbkdf2_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/pbkdf2"
+)
+
+var testArgon2id = pbkdf2.Argon2id{
+	Time:     1,
+	Memory:   64,
+	Threads:  2,
+	SaltSize: 16,
+	KeySize:  32,
+}
+
+func TestPBKDF2Derive(t *testing.T) {
+	t.Parallel()
+
+	kdf := pbkdf2.PBKDF2{
+		Iterations: pbkdf2.Iterations,
+		SaltSize:   len(salt),
+		KeySize:    32,
+	}
+
+	key, err := kdf.Derive([]byte(password), []byte(salt))
+	assert.NoError(t, err)
+
+	expected := pbkdf2.Key([]byte(password), []byte(salt))
+	assert.Equal(t, expected[:], key, "derived key does not match Key")
+
+	kdf.KeySize = 64
+	long, err := kdf.Derive([]byte(password), []byte(salt))
+	assert.NoError(t, err)
+	assert.Len(t, long, 64)
+	assert.Equal(t, key, long[:32])
+
+	_, err = kdf.Derive([]byte(password), []byte("short"))
+	assert.Equal(t, pbkdf2.ErrInvalidSalt, err)
+
+	kdf.Iterations = 0
+	_, err = kdf.Derive([]byte(password), []byte(salt))
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+}
+
+func TestArgon2idDerive(t *testing.T) {
+	t.Parallel()
+
+	s1, err := testArgon2id.NewSalt()
+	assert.NoError(t, err)
+	assert.Len(t, s1, testArgon2id.SaltSize)
+
+	s2, err := testArgon2id.NewSalt()
+	assert.NoError(t, err)
+	assert.NotEqual(t, s1, s2)
+
+	k1, err := testArgon2id.Derive([]byte(password), s1)
+	assert.NoError(t, err)
+	assert.Len(t, k1, testArgon2id.KeySize)
+
+	again, err := testArgon2id.Derive([]byte(password), s1)
+	assert.NoError(t, err)
+	assert.Equal(t, k1, again)
+
+	k2, err := testArgon2id.Derive([]byte(password), s2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+
+	_, err = testArgon2id.Derive([]byte(password), s1[1:])
+	assert.Equal(t, pbkdf2.ErrInvalidSalt, err)
+
+	invalid := testArgon2id
+	invalid.Memory = 8
+	_, err = invalid.Derive([]byte(password), s1)
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+}
+
+func TestArgon2idVector(t *testing.T) {
+	t.Parallel()
+
+	key, err := testArgon2id.Derive([]byte(password), []byte("0123456789abcdef"))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"4237936c948dc1a9f89b9b09e6ce5348868b2fdc36867a9142f423a91eaaada0",
+		hex.EncodeToString(key))
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, kdf := range []pbkdf2.PasswordKDF{
+		pbkdf2.DefaultPBKDF2,
+		pbkdf2.DefaultArgon2id,
+		testArgon2id,
+	} {
+		parsed, err := pbkdf2.Parse(kdf.String())
+		assert.NoError(t, err)
+		assert.Equal(t, kdf, parsed)
+	}
+
+	assert.Equal(t, "cryptor-pbkdf2-blake2s$i=131072,s=16,k=32",
+		pbkdf2.DefaultPBKDF2.String())
+	assert.Equal(t, "argon2id$v=19,t=3,m=65536,p=4,s=16,k=32",
+		pbkdf2.DefaultArgon2id.String())
+
+	parsed, err := pbkdf2.Parse("pbkdf2-blake2s$i=131072,s=16,k=32")
+	assert.NoError(t, err)
+	assert.Equal(t, pbkdf2.DefaultPBKDF2, parsed)
+
+	_, err = pbkdf2.Parse("scrypt$n=16384,r=8,p=1")
+	assert.Equal(t, pbkdf2.ErrUnknownKDF, err)
+
+	for _, encoded := range []string{
+		"",
+		"cryptor-pbkdf2-blake2s",
+		"cryptor-pbkdf2-blake2s$",
+		"cryptor-pbkdf2-blake2s$i=1000,s=16",
+		"cryptor-pbkdf2-blake2s$i=1000,s=16,k=32,x=1",
+		"cryptor-pbkdf2-blake2s$s=16,i=1000,k=32",
+		"cryptor-pbkdf2-blake2s$i=01000,s=16,k=32",
+		"cryptor-pbkdf2-blake2s$i=-1,s=16,k=32",
+		"cryptor-pbkdf2-blake2s$i=0,s=16,k=32",
+		"cryptor-pbkdf2-blake2s$i=1000,s=4,k=32",
+		"cryptor-pbkdf2-blake2s$i=1000,s=16,k=8",
+		"cryptor-pbkdf2-blake2s$i=1000,s=16,k=4096",
+		"cryptor-pbkdf2-blake2s$i=4294967295,s=16,k=32",
+		"argon2id$v=16,t=3,m=65536,p=4,s=16,k=32",
+		"argon2id$v=19,t=0,m=65536,p=4,s=16,k=32",
+		"argon2id$v=19,t=3,m=65536,p=256,s=16,k=32",
+		"argon2id$v=19,t=3,m=16,p=4,s=16,k=32",
+		"argon2id$v=19,t=4294967295,m=4294967295,p=255,s=16,k=32",
+	} {
+		_, err := pbkdf2.Parse(encoded)
+		assert.Equal(t, pbkdf2.ErrInvalidParams, err, encoded)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	t.Parallel()
+
+	pbkdf2Params := "cryptor-pbkdf2-blake2s$i=%d,s=16,k=32"
+	argon2Params := "argon2id$v=19,t=%d,m=%d,p=%d,s=16,k=32"
+
+	for _, encoded := range []string{
+		fmt.Sprintf(pbkdf2Params, pbkdf2.MaxIterations),
+		fmt.Sprintf(argon2Params, pbkdf2.MaxArgon2Time, 65536, 4),
+		fmt.Sprintf(argon2Params, 3, pbkdf2.MaxArgon2Memory, 4),
+		fmt.Sprintf(argon2Params, 3, 65536, pbkdf2.MaxArgon2Threads),
+	} {
+		parsed, err := pbkdf2.Parse(encoded)
+		assert.NoError(t, err, encoded)
+		assert.Equal(t, encoded, parsed.String())
+	}
+
+	for _, encoded := range []string{
+		fmt.Sprintf(pbkdf2Params, pbkdf2.MaxIterations+1),
+		fmt.Sprintf(argon2Params, pbkdf2.MaxArgon2Time+1, 65536, 4),
+		fmt.Sprintf(argon2Params, 3, pbkdf2.MaxArgon2Memory+1, 4),
+		fmt.Sprintf(argon2Params, 3, 65536, pbkdf2.MaxArgon2Threads+1),
+	} {
+		_, err := pbkdf2.Parse(encoded)
+		assert.Equal(t, pbkdf2.ErrInvalidParams, err, encoded)
+	}
+}
+
+func TestPBKDF2Hash(t *testing.T) {
+	t.Parallel()
+
+	kdf := pbkdf2.PBKDF2{
+		Iterations: 1000,
+		SaltSize:   16,
+		KeySize:    32,
+		Hash:       hashing.SHA256,
+	}
+	assert.Equal(t, "cryptor-pbkdf2-sha256", kdf.ID())
+	assert.Equal(t, "cryptor-pbkdf2-sha256$i=1000,s=16,k=32", kdf.String())
+
+	parsed, err := pbkdf2.Parse(kdf.String())
+	assert.NoError(t, err)
+	assert.Equal(t, kdf, parsed)
+
+	salt := []byte("0123456789abcdef")
+	key, err := kdf.Derive([]byte(password), salt)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		xpbkdf2.Key([]byte(password), salt, 1000, 32, sha256.New), key)
+
+	blake, err := pbkdf2.PBKDF2{Iterations: 1000, SaltSize: 16, KeySize: 32}.
+		Derive([]byte(password), salt)
+	assert.NoError(t, err)
+	assert.NotEqual(t, blake, key)
+
+	sha3 := kdf
+	sha3.Hash = hashing.SHA3_256
+	assert.Equal(t, "cryptor-pbkdf2-sha3-256$i=1000,s=16,k=32", sha3.String())
+	parsed, err = pbkdf2.Parse(sha3.String())
+	assert.NoError(t, err)
+	assert.Equal(t, sha3, parsed)
+
+	for _, encoded := range []string{
+		"cryptor-pbkdf2-md5$i=1000,s=16,k=32",
+		"cryptor-pbkdf2-SHA256$i=1000,s=16,k=32",
+		"cryptor-pbkdf2-$i=1000,s=16,k=32",
+	} {
+		_, err := pbkdf2.Parse(encoded)
+		assert.Equal(t, pbkdf2.ErrUnknownKDF, err, encoded)
+	}
+
+	kdf.Hash = hashing.Algorithm(0xFF)
+	assert.Equal(t, pbkdf2.ErrInvalidParams, kdf.Validate())
+}
@@ -0,0 +1,99 @@
+package pbkdf2
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"cpl.li/go/cryptor/internal/crypt"
+)
+
+// DefaultKDF is used by HashPassword.
+var DefaultKDF PasswordKDF = DefaultPBKDF2
+
+// ErrInvalidHash is returned when an encoded password hash is malformed.
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Password hashes are encoded PHC style, as the KDF parameters followed by the
+// salt and derived key, using unpadded standard base64:
+//
+//	$cryptor-pbkdf2-blake2s$i=131072,s=16,k=32$<salt>$<hash>
+var hashEncoding = base64.RawStdEncoding
+
+// HashPassword returns the encoded hash of the password, derived by
+// DefaultKDF with a random salt.
+func HashPassword(password []byte) (string, error) {
+	return HashPasswordKDF(password, DefaultKDF)
+}
+
+// HashPasswordKDF returns the encoded hash of the password, derived by the KDF
+// with a random salt.
+func HashPasswordKDF(password []byte, kdf PasswordKDF) (string, error) {
+	salt, err := kdf.NewSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := kdf.Derive(password, salt)
+	if err != nil {
+		return "", err
+	}
+	defer crypt.ZeroBytes(key)
+
+	return "$" + kdf.String() +
+		"$" + hashEncoding.EncodeToString(salt) +
+		"$" + hashEncoding.EncodeToString(key), nil
+}
+
+// VerifyPassword returns true if the password matches the encoded hash. The
+// derived keys are compared in constant time.
+func VerifyPassword(password []byte, encoded string) (bool, error) {
+	kdf, salt, hash, err := decodeHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := kdf.Derive(password, salt)
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	defer crypt.ZeroBytes(key)
+
+	if len(key) != len(hash) {
+		return false, ErrInvalidHash
+	}
+
+	return subtle.ConstantTimeCompare(key, hash) == 1, nil
+}
+
+// NeedsRehash returns true if the encoded hash was not derived using the
+// given KDF parameters, so the password should be hashed again once verified.
+func NeedsRehash(encoded string, kdf PasswordKDF) (bool, error) {
+	current, _, _, err := decodeHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	return current.String() != kdf.String(), nil
+}
+
+func decodeHash(encoded string) (kdf PasswordKDF, salt, hash []byte, err error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 5 || fields[0] != "" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	if kdf, err = Parse(fields[1] + "$" + fields[2]); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if salt, err = hashEncoding.DecodeString(fields[3]); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	if hash, err = hashEncoding.DecodeString(fields[4]); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return kdf, salt, hash, nil
+}
@@ -0,0 +1,137 @@
+package pbkdf2_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/pbkdf2"
+)
+
+var testPBKDF2 = pbkdf2.PBKDF2{Iterations: 1024, SaltSize: 16, KeySize: 32}
+
+func TestHashPassword(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := pbkdf2.HashPassword([]byte(password))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded,
+		"$cryptor-pbkdf2-blake2s$i=131072,s=16,k=32$"))
+	assert.Len(t, strings.Split(encoded, "$"), 5)
+
+	ok, err := pbkdf2.VerifyPassword([]byte(password), encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = pbkdf2.VerifyPassword([]byte("wrong"), encoded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// same password, different salt
+	other, err := pbkdf2.HashPassword([]byte(password))
+	assert.NoError(t, err)
+	assert.NotEqual(t, encoded, other)
+}
+
+func TestHashPasswordKDF(t *testing.T) {
+	t.Parallel()
+
+	for _, kdf := range []pbkdf2.PasswordKDF{testPBKDF2, testArgon2id} {
+		encoded, err := pbkdf2.HashPasswordKDF([]byte(password), kdf)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encoded, "$"+kdf.String()+"$"))
+
+		ok, err := pbkdf2.VerifyPassword([]byte(password), encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = pbkdf2.VerifyPassword([]byte(password+"."), encoded)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	_, err := pbkdf2.HashPasswordKDF([]byte(password), pbkdf2.PBKDF2{})
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+}
+
+func TestVerifyPasswordVector(t *testing.T) {
+	t.Parallel()
+
+	// salt and hash of pbkdf2.Key with the default salt
+	encoded := "$cryptor-pbkdf2-blake2s$i=131072,s=12,k=32" +
+		"$Li1fY3J5cHRvciwk$KN8Lk2J9W1DtT+9XTndKAKxjTL0zldClfnaVgegG+C8"
+
+	ok, err := pbkdf2.VerifyPassword([]byte(password), encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// legacy identifier
+	ok, err = pbkdf2.VerifyPassword([]byte(password), strings.Replace(
+		encoded, "cryptor-pbkdf2-blake2s", "pbkdf2-blake2s", 1))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestVerifyPasswordInvalid(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := pbkdf2.HashPasswordKDF([]byte(password), testPBKDF2)
+	assert.NoError(t, err)
+	fields := strings.Split(encoded, "$")
+
+	for _, invalid := range []string{
+		"",
+		"$",
+		encoded[1:],
+		encoded + "$",
+		strings.Join(fields[:4], "$"),
+		strings.Join([]string{"", fields[1], fields[2], "!!", fields[4]}, "$"),
+		strings.Join([]string{"", fields[1], fields[2], fields[3], "!!"}, "$"),
+		strings.Join([]string{"", fields[1], fields[2], fields[3], fields[4] + "AA"}, "$"),
+		strings.Join([]string{"", fields[1], fields[2], fields[3] + "AA", fields[4]}, "$"),
+	} {
+		_, err := pbkdf2.VerifyPassword([]byte(password), invalid)
+		assert.Equal(t, pbkdf2.ErrInvalidHash, err, invalid)
+	}
+
+	_, err = pbkdf2.VerifyPassword([]byte(password),
+		"$scrypt$n=1$"+fields[3]+"$"+fields[4])
+	assert.Equal(t, pbkdf2.ErrUnknownKDF, err)
+
+	_, err = pbkdf2.VerifyPassword([]byte(password),
+		"$cryptor-pbkdf2-blake2s$i=0,s=16,k=32$"+fields[3]+"$"+fields[4])
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+
+	_, err = pbkdf2.VerifyPassword([]byte(password),
+		"$cryptor-pbkdf2-blake2s$i=4294967295,s=16,k=32$"+fields[3]+"$"+fields[4])
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+
+	_, err = pbkdf2.VerifyPassword([]byte(password),
+		"$argon2id$v=19,t=4294967295,m=4294967295,p=255,s=16,k=32$"+fields[3]+"$"+fields[4])
+	assert.Equal(t, pbkdf2.ErrInvalidParams, err)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := pbkdf2.HashPasswordKDF([]byte(password), testPBKDF2)
+	assert.NoError(t, err)
+
+	rehash, err := pbkdf2.NeedsRehash(encoded, testPBKDF2)
+	assert.NoError(t, err)
+	assert.False(t, rehash)
+
+	stronger := testPBKDF2
+	stronger.Iterations *= 2
+	rehash, err = pbkdf2.NeedsRehash(encoded, stronger)
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+
+	rehash, err = pbkdf2.NeedsRehash(encoded, testArgon2id)
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+
+	_, err = pbkdf2.NeedsRehash("invalid", testPBKDF2)
+	assert.Equal(t, pbkdf2.ErrInvalidHash, err)
+}
@@ -12,9 +12,15 @@ const (
 	iter       = 131072
 )
 
+// Iterations is the number of pbkdf2 rounds performed by Key.
+const Iterations = iter
+
 // Key will derive a 32byte key from the given password and salt using pbkdf2,
 // the Cryptor hashing.HashFunction. If a nil salt is given, the default salt
 // is used.
+//
+// Key derives the same key for the same password whenever the default salt is
+// used, new code should use a PasswordKDF with a random salt instead.
 func Key(password, salt []byte) (key [ppk.KeySize]byte) {
 	if salt == nil {
 		salt = []byte(staticSalt)
@@ -2,16 +2,28 @@ package ppk
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 
 	"golang.org/x/crypto/curve25519"
 )
 
 // NewPrivateKey ...
 func NewPrivateKey(sk *PrivateKey) error {
+	return NewPrivateKeyFrom(rand.Reader, sk)
+}
+
+// NewPrivateKeyFrom generates a private key reading its bytes from r, which
+// should be a cryptographically secure source unless used for testing.
+func NewPrivateKeyFrom(r io.Reader, sk *PrivateKey) error {
 	if sk != nil {
-		_, err := rand.Read(sk[:])
-		return err
+		if _, err := io.ReadFull(r, sk[:]); err != nil {
+			return err
+		}
+		sk.Clamp()
+
+		return nil
 	}
 
 	return fmt.Errorf("must provide key pointer")
@@ -29,11 +41,21 @@ func (sk *PrivateKey) PublicKey(pk *PublicKey) error {
 	return fmt.Errorf("must provide key pointer")
 }
 
-// SharedSecret ...
-func (sk *PrivateKey) SharedSecret(pk *PublicKey, ss *[KeySize]byte) {
-	curve25519.ScalarMult(ss,
-		(*[KeySize]byte)(sk),
-		(*[KeySize]byte)(pk))
+// ErrLowOrderPoint is returned by SharedSecret when the public key is a low
+// order point, which would result in an all-zero shared secret.
+var ErrLowOrderPoint = errors.New("low order public key")
+
+// SharedSecret writes the X25519 shared secret of the key pair to ss. Public
+// keys resulting in an all-zero secret are rejected with ErrLowOrderPoint and
+// leave ss zeroed.
+func (sk *PrivateKey) SharedSecret(pk *PublicKey, ss *[KeySize]byte) error {
+	secret, err := curve25519.X25519(sk[:], pk[:])
+	if err != nil {
+		*ss = [KeySize]byte{}
+		return ErrLowOrderPoint
+	}
+
+	copy(ss[:], secret)
 
-	return
+	return nil
 }
@@ -0,0 +1,145 @@
+package ppk
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/hkdf"
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+)
+
+// MinSeedSize is the minimum size of a seed accepted by NewPrivateKeyFromSeed,
+// matching the entropy of the shortest mnemonic.
+const MinSeedSize = 16
+
+// Domain separation for seed and child derivation, so a child key can never
+// collide with the key derived from a seed holding the parent key bytes.
+const (
+	seedSalt  = "cryptor ppk seed v1"
+	childSalt = "cryptor ppk child v1"
+)
+
+var (
+	// ErrShortSeed is returned when deriving a key from a seed shorter than
+	// MinSeedSize.
+	ErrShortSeed = errors.New("seed too short")
+
+	// ErrInvalidPath is returned when a derivation path can't be parsed.
+	ErrInvalidPath = errors.New("invalid derivation path")
+)
+
+// Clamp clamps the private key as described for X25519, clearing the three
+// lowest bits and the highest bit and setting the second highest bit.
+// Curve25519 operations clamp implicitly, clamping explicitly only makes the
+// stored key canonical.
+func (sk *PrivateKey) Clamp() {
+	sk[0] &= 248
+	sk[31] &= 127
+	sk[31] |= 64
+}
+
+// NewPrivateKeyFromSeed deterministically derives the master private key of
+// the seed, the root of all keys derived with DeriveChild and DerivePath.
+func NewPrivateKeyFromSeed(seed []byte, sk *PrivateKey) error {
+	if sk == nil {
+		return errors.New("must provide key pointer")
+	}
+	if len(seed) < MinSeedSize {
+		return ErrShortSeed
+	}
+
+	prk := hkdf.Extract([]byte(seedSalt), seed)
+	defer crypt.ZeroBytes(prk[:])
+
+	return expandKey(prk[:], nil, sk)
+}
+
+// NewPrivateKeyFromMnemonic derives the master private key of the entropy
+// encoded by the mnemonic, which may be of any valid length. Unlike
+// PrivateKey.FromMnemonic, which loads the key bytes themselves, the mnemonic
+// is a recovery phrase from which any number of keys can be derived.
+func NewPrivateKeyFromMnemonic(mnemonic mwords.MnemonicSentence, sk *PrivateKey) error {
+	if !mnemonic.IsValid() {
+		return errors.New("invalid mnemonic")
+	}
+
+	seed, err := mwords.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+	defer crypt.ZeroBytes(seed)
+
+	return NewPrivateKeyFromSeed(seed, sk)
+}
+
+// DeriveChild deterministically derives the child of the parent key with the
+// given label and index. Children with different labels or indexes are
+// independent and knowing a child doesn't reveal its parent.
+func DeriveChild(parent *PrivateKey, label string, index uint32, child *PrivateKey) error {
+	if parent == nil || child == nil {
+		return errors.New("must provide key pointer")
+	}
+
+	prk := hkdf.Extract([]byte(childSalt), parent[:])
+	defer crypt.ZeroBytes(prk[:])
+
+	// info is the length prefixed label followed by the index
+	info := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(label)+4)
+	info = info[:binary.PutUvarint(info, uint64(len(label)))]
+	info = append(info, label...)
+	info = append(info, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+
+	return expandKey(prk[:], info, child)
+}
+
+// DerivePath derives the descendant of the parent key at the given path,
+// a list of "label:index" elements separated by slashes such as
+// "device:1/app:0". Labels can't contain either separator. An empty path
+// returns the parent itself.
+func DerivePath(parent *PrivateKey, path string, child *PrivateKey) error {
+	if parent == nil || child == nil {
+		return errors.New("must provide key pointer")
+	}
+
+	key := *parent
+	defer crypt.ZeroBytes(key[:])
+
+	if path != "" {
+		for _, element := range strings.Split(path, "/") {
+			separator := strings.IndexByte(element, ':')
+			if separator < 0 {
+				return ErrInvalidPath
+			}
+
+			label := element[:separator]
+			index, err := strconv.ParseUint(element[separator+1:], 10, 32)
+			if err != nil {
+				return ErrInvalidPath
+			}
+
+			if err := DeriveChild(&key, label, uint32(index), &key); err != nil {
+				return err
+			}
+		}
+	}
+
+	*child = key
+
+	return nil
+}
+
+func expandKey(prk, info []byte, sk *PrivateKey) error {
+	key, err := hkdf.Expand(prk, info, KeySize)
+	if err != nil {
+		return err
+	}
+	defer crypt.ZeroBytes(key)
+
+	copy(sk[:], key)
+	sk.Clamp()
+
+	return nil
+}
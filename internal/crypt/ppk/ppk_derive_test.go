@@ -0,0 +1,127 @@
+package ppk_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+const testSeed = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestClamp(t *testing.T) {
+	t.Parallel()
+
+	sk := ppk.PrivateKey{}
+	for index := range sk {
+		sk[index] = 0xFF
+	}
+	sk.Clamp()
+	assert.Equal(t, byte(0xF8), sk[0])
+	assert.Equal(t, byte(0x7F), sk[31])
+
+	sk = ppk.PrivateKey{}
+	sk.Clamp()
+	assert.Equal(t, byte(0x40), sk[31])
+
+	// clamping doesn't change the public key
+	var clamped ppk.PrivateKey
+	var pk, clampedPK ppk.PublicKey
+	assert.NoError(t, sk.FromHex(testPrivateKey))
+	clamped = sk
+	clamped.Clamp()
+	assert.NoError(t, sk.PublicKey(&pk))
+	assert.NoError(t, clamped.PublicKey(&clampedPK))
+	assert.True(t, pk.Equals(clampedPK))
+
+	// generated keys are clamped
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+	clamped = sk
+	clamped.Clamp()
+	assert.True(t, sk.Equals(clamped))
+}
+
+func TestDerive(t *testing.T) {
+	t.Parallel()
+
+	seed, _ := hex.DecodeString(testSeed)
+
+	var master, child ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKeyFromSeed(seed, &master))
+	assert.Equal(t,
+		"606c8be068c753b05be4272664110a0d562a8d03ada015885d5a791f6ed58267",
+		master.ToHex())
+
+	for _, test := range []struct {
+		label string
+		index uint32
+		key   string
+	}{
+		{"node", 0, "000bc8e9b4beed70a83c0ebcdfa9e89ffe3949d826aeb5adb339ab7e4b9cd148"},
+		{"device", 1, "187eacccf7d69935122e30e2ac23f8b17255ffb8e4a3fe39d06e4f650e3e994e"},
+	} {
+		assert.NoError(t, ppk.DeriveChild(&master, test.label, test.index, &child))
+		assert.Equal(t, test.key, child.ToHex())
+	}
+
+	for _, test := range []struct {
+		path, key string
+	}{
+		{"", master.ToHex()},
+		{"node:0", "000bc8e9b4beed70a83c0ebcdfa9e89ffe3949d826aeb5adb339ab7e4b9cd148"},
+		{"device:1/app:0", "882b909f6963e62bcc923e561884368cda9619ff0feba9e9407ef94199d5c853"},
+	} {
+		assert.NoError(t, ppk.DerivePath(&master, test.path, &child))
+		assert.Equal(t, test.key, child.ToHex(), test.path)
+	}
+
+	// siblings are distinct
+	var other ppk.PrivateKey
+	assert.NoError(t, ppk.DeriveChild(&master, "device", 2, &other))
+	assert.NoError(t, ppk.DeriveChild(&master, "device", 1, &child))
+	assert.False(t, child.Equals(other))
+	assert.NoError(t, ppk.DeriveChild(&master, "devic", 1, &other))
+	assert.False(t, child.Equals(other))
+
+	// deriving in place
+	other = master
+	assert.NoError(t, ppk.DeriveChild(&other, "device", 1, &other))
+	assert.True(t, child.Equals(other))
+}
+
+func TestDeriveMnemonic(t *testing.T) {
+	t.Parallel()
+
+	mnemonic, err := mwords.MnemonicFromString(
+		"abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon about")
+	assert.NoError(t, err)
+
+	var sk ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKeyFromMnemonic(mnemonic, &sk))
+	assert.Equal(t,
+		"a0c6772f759cfb1a4e858bf4721d86fed8353f35ccf028048bde03273cfcde54",
+		sk.ToHex())
+
+	mnemonic[0] = "zoo"
+	assert.Error(t, ppk.NewPrivateKeyFromMnemonic(mnemonic, &sk))
+}
+
+func TestDeriveInvalid(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.PrivateKey
+	assert.Equal(t, ppk.ErrShortSeed, ppk.NewPrivateKeyFromSeed(make([]byte, 15), &sk))
+	assert.Error(t, ppk.NewPrivateKeyFromSeed(make([]byte, 16), nil))
+	assert.Error(t, ppk.DeriveChild(nil, "node", 0, &sk))
+	assert.Error(t, ppk.DeriveChild(&sk, "node", 0, nil))
+
+	for _, path := range []string{
+		"node", "node:", "node:-1", "node:4294967296", "node:0/", "/node:0", "node:0:1",
+	} {
+		assert.Equal(t, ppk.ErrInvalidPath, ppk.DerivePath(&sk, path, &sk), path)
+	}
+}
@@ -0,0 +1,95 @@
+package ppk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+)
+
+const (
+	// FingerprintSize is the size of a public key fingerprint.
+	FingerprintSize = 30
+
+	// FingerprintWords is the number of words returned by Fingerprint.Words,
+	// covering the first 88 bits of the fingerprint.
+	FingerprintWords = 8
+
+	fingerprintLabel = "cryptor fingerprint v1"
+
+	// each group of digits encodes 5 bytes of the fingerprint
+	groupSize   = 5
+	groupDigits = 100000
+)
+
+// Fingerprint is a short hash of a public key, meant to be compared by
+// people instead of the key itself.
+type Fingerprint [FingerprintSize]byte
+
+// Fingerprint writes the fingerprint of the public key to fp.
+func (pk *PublicKey) Fingerprint(fp *Fingerprint) {
+	var sum hashing.HashSum
+	hashing.HashLabel(&sum, fingerprintLabel, pk[:])
+
+	copy(fp[:], sum[:])
+}
+
+// Digits returns the fingerprint as 6 space separated groups of 5 digits.
+func (fp *Fingerprint) Digits() string {
+	groups := make([]string, 0, FingerprintSize/groupSize)
+
+	for offset := 0; offset < FingerprintSize; offset += groupSize {
+		var chunk [8]byte
+		copy(chunk[8-groupSize:], fp[offset:offset+groupSize])
+
+		groups = append(groups, fmt.Sprintf("%05d",
+			binary.BigEndian.Uint64(chunk[:])%groupDigits))
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// Words returns the first 88 bits of the fingerprint as FingerprintWords
+// words of the mnemonic word list, 11 bits each.
+func (fp *Fingerprint) Words() []string {
+	words := make([]string, FingerprintWords)
+
+	var (
+		buffer uint32
+		bits   uint
+		offset int
+	)
+
+	for index := range words {
+		for bits < 11 {
+			buffer = buffer<<8 | uint32(fp[offset])
+			bits += 8
+			offset++
+		}
+
+		bits -= 11
+		words[index] = mwords.Word(int(buffer>>bits) & (mwords.Count - 1))
+	}
+
+	return words
+}
+
+// SafetyNumber returns the safety number of two peers, the digits of both
+// fingerprints ordered so that both peers see the same 12 groups of 5 digits
+// whichever key is passed first. Each peer can recognize the half belonging
+// to its own key, and a key can only be replaced by finding another key with
+// the same fingerprint.
+func SafetyNumber(a, b *PublicKey) string {
+	var fpA, fpB Fingerprint
+	a.Fingerprint(&fpA)
+	b.Fingerprint(&fpB)
+
+	if bytes.Compare(fpA[:], fpB[:]) > 0 {
+		fpA, fpB = fpB, fpA
+	}
+
+	return fpA.Digits() + " " + fpB.Digits()
+}
@@ -0,0 +1,65 @@
+package ppk_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+const testOtherPublicKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		pub, digits, words string
+	}{
+		{
+			testPublicKey,
+			"31382 57877 75984 81592 09415 84143",
+			"immune order cry under juice local ski pistol",
+		},
+		{
+			testOtherPublicKey,
+			"30253 66142 31440 91616 84017 39280",
+			"firm soft satoshi surprise shaft brisk cliff spatial",
+		},
+	} {
+		var pk ppk.PublicKey
+		var fp ppk.Fingerprint
+		assert.NoError(t, pk.FromHex(test.pub))
+
+		pk.Fingerprint(&fp)
+		assert.Equal(t, test.digits, fp.Digits())
+		assert.Equal(t, test.words, strings.Join(fp.Words(), " "))
+		assert.Len(t, fp.Words(), ppk.FingerprintWords)
+	}
+}
+
+func TestSafetyNumber(t *testing.T) {
+	t.Parallel()
+
+	var a, b ppk.PublicKey
+	assert.NoError(t, a.FromHex(testPublicKey))
+	assert.NoError(t, b.FromHex(testOtherPublicKey))
+
+	expected := "30253 66142 31440 91616 84017 39280 " +
+		"31382 57877 75984 81592 09415 84143"
+	assert.Equal(t, expected, ppk.SafetyNumber(&a, &b))
+	assert.Equal(t, expected, ppk.SafetyNumber(&b, &a))
+
+	// replacing either key changes the safety number
+	for iter := 0; iter < 16; iter++ {
+		var sk ppk.PrivateKey
+		var other ppk.PublicKey
+		assert.NoError(t, ppk.NewPrivateKey(&sk))
+		assert.NoError(t, sk.PublicKey(&other))
+
+		assert.NotEqual(t, expected, ppk.SafetyNumber(&a, &other))
+		assert.NotEqual(t, expected, ppk.SafetyNumber(&other, &b))
+		assert.Equal(t, ppk.SafetyNumber(&a, &other), ppk.SafetyNumber(&other, &a))
+	}
+}
@@ -0,0 +1,222 @@
+package ppk
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/mwords"
+)
+
+// SignatureSize is the size of an Ed25519 signature.
+const SignatureSize = ed25519.SignatureSize
+
+// bindingLabel prefixes the X25519 public key signed by Bind.
+const bindingLabel = "cryptor binding v1 ed25519 x25519"
+
+// SigningKey is the private half of an Ed25519 signing identity, stored as
+// its RFC 8032 seed.
+type SigningKey [KeySize]byte
+
+// VerifyKey is the public half of an Ed25519 signing identity.
+type VerifyKey [KeySize]byte
+
+// Signature is an Ed25519 signature.
+type Signature [SignatureSize]byte
+
+// curve25519P is the field prime 2^255 - 19.
+var curve25519P, _ = new(big.Int).SetString(
+	"7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+
+// NewSigningKey ...
+func NewSigningKey(sk *SigningKey) error {
+	if sk != nil {
+		_, err := rand.Read(sk[:])
+		return err
+	}
+
+	return fmt.Errorf("must provide key pointer")
+}
+
+// VerifyKey ...
+func (sk *SigningKey) VerifyKey(vk *VerifyKey) error {
+	if vk != nil {
+		priv := ed25519.NewKeyFromSeed(sk[:])
+		defer crypt.ZeroBytes(priv)
+
+		copy(vk[:], priv.Public().(ed25519.PublicKey))
+		return nil
+	}
+
+	return fmt.Errorf("must provide key pointer")
+}
+
+// Sign writes the signature of the message to sig.
+func (sk *SigningKey) Sign(msg []byte, sig *Signature) {
+	priv := ed25519.NewKeyFromSeed(sk[:])
+	defer crypt.ZeroBytes(priv)
+
+	copy(sig[:], ed25519.Sign(priv, msg))
+}
+
+// Verify returns true if sig is a valid signature of the message.
+func (vk *VerifyKey) Verify(msg []byte, sig *Signature) bool {
+	return ed25519.Verify(vk[:], msg, sig[:])
+}
+
+// PrivateKey converts the signing key into the X25519 private key sharing
+// its secret scalar, the clamped first half of the SHA-512 of the seed. The
+// public key of the result is the one returned by VerifyKey.PublicKey, so a
+// single seed can serve as both the signing and the Diffie-Hellman identity
+// of a node.
+func (sk *SigningKey) PrivateKey(dh *PrivateKey) error {
+	if dh == nil {
+		return fmt.Errorf("must provide key pointer")
+	}
+
+	digest := sha512.Sum512(sk[:])
+	defer crypt.ZeroBytes(digest[:])
+
+	copy(dh[:], digest[:KeySize])
+	dh.Clamp()
+
+	return nil
+}
+
+// PublicKey converts the Ed25519 verify key into the X25519 public key of
+// the same point, using the birational map u = (1 + y) / (1 - y) from the
+// Edwards y coordinate to the Montgomery u coordinate.
+func (vk *VerifyKey) PublicKey(pk *PublicKey) error {
+	if pk == nil {
+		return fmt.Errorf("must provide key pointer")
+	}
+
+	var y [KeySize]byte
+	copy(y[:], vk[:])
+	y[31] &= 0x7F // drop the sign of x
+	reverse(y[:])
+
+	yInt := new(big.Int).SetBytes(y[:])
+	if yInt.Cmp(curve25519P) >= 0 {
+		return errors.New("invalid verify key")
+	}
+
+	denominator := new(big.Int).Sub(big.NewInt(1), yInt)
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return errors.New("invalid verify key")
+	}
+	denominator.ModInverse(denominator, curve25519P)
+
+	u := new(big.Int).Add(big.NewInt(1), yInt)
+	u.Mul(u, denominator)
+	u.Mod(u, curve25519P)
+
+	var out [KeySize]byte
+	uBytes := u.Bytes()
+	copy(out[KeySize-len(uBytes):], uBytes)
+	reverse(out[:])
+	*pk = out
+
+	return nil
+}
+
+// Bind signs the X25519 public key, binding it to the signing identity. It
+// is meant for nodes whose static key is not derived from their signing key.
+func (sk *SigningKey) Bind(pk *PublicKey, sig *Signature) {
+	sk.Sign(bindingMessage(pk), sig)
+}
+
+// VerifyBinding returns true if sig binds the X25519 public key to the
+// signing identity, as produced by Bind.
+func (vk *VerifyKey) VerifyBinding(pk *PublicKey, sig *Signature) bool {
+	return vk.Verify(bindingMessage(pk), sig)
+}
+
+func bindingMessage(pk *PublicKey) []byte {
+	return append([]byte(bindingLabel), pk[:]...)
+}
+
+func reverse(b []byte) {
+	for left, right := 0, len(b)-1; left < right; left, right = left+1, right-1 {
+		b[left], b[right] = b[right], b[left]
+	}
+}
+
+// ToHex ...
+func (sk *SigningKey) ToHex() string {
+	return hex.EncodeToString(sk[:])
+}
+
+// FromHex ...
+func (sk *SigningKey) FromHex(src string) error {
+	return loadHexKey(src, sk[:])
+}
+
+// Equals ...
+func (sk *SigningKey) Equals(to SigningKey) bool {
+	return subtle.ConstantTimeCompare(sk[:], to[:]) == 1
+}
+
+// IsZero ...
+func (sk *SigningKey) IsZero() bool {
+	return sk.Equals(SigningKey{})
+}
+
+// ToMnemonic ...
+func (sk *SigningKey) ToMnemonic() mwords.MnemonicSentence {
+	mnemonic, _ := mwords.EntropyToMnemonic(sk[:])
+	return mnemonic
+}
+
+// FromMnemonic ...
+func (sk *SigningKey) FromMnemonic(mnemonic mwords.MnemonicSentence) error {
+	return loadMnemonicKey(mnemonic, sk[:])
+}
+
+// ToHex ...
+func (vk *VerifyKey) ToHex() string {
+	return hex.EncodeToString(vk[:])
+}
+
+// FromHex ...
+func (vk *VerifyKey) FromHex(src string) error {
+	return loadHexKey(src, vk[:])
+}
+
+// Equals ...
+func (vk *VerifyKey) Equals(to VerifyKey) bool {
+	return subtle.ConstantTimeCompare(vk[:], to[:]) == 1
+}
+
+// IsZero ...
+func (vk *VerifyKey) IsZero() bool {
+	return vk.Equals(VerifyKey{})
+}
+
+// ToMnemonic ...
+func (vk *VerifyKey) ToMnemonic() mwords.MnemonicSentence {
+	mnemonic, _ := mwords.EntropyToMnemonic(vk[:])
+	return mnemonic
+}
+
+// FromMnemonic ...
+func (vk *VerifyKey) FromMnemonic(mnemonic mwords.MnemonicSentence) error {
+	return loadMnemonicKey(mnemonic, vk[:])
+}
+
+// ToHex ...
+func (sig *Signature) ToHex() string {
+	return hex.EncodeToString(sig[:])
+}
+
+// FromHex ...
+func (sig *Signature) FromHex(src string) error {
+	return loadHexKey(src, sig[:])
+}
@@ -0,0 +1,152 @@
+package ppk_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// RFC 8032 section 7.1, test 1 and test 2
+var signingVectors = []struct {
+	seed, verify, msg, sig string
+}{
+	{
+		seed:   "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		verify: "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		msg:    "",
+		sig: "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555" +
+			"fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+	},
+	{
+		seed:   "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		verify: "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		msg:    "\x72",
+		sig: "92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da0" +
+			"85ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+	},
+}
+
+func TestSigning(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range signingVectors {
+		var sk ppk.SigningKey
+		var vk, expected ppk.VerifyKey
+		var sig, expectedSig ppk.Signature
+
+		assert.NoError(t, sk.FromHex(test.seed))
+		assert.NoError(t, expected.FromHex(test.verify))
+		assert.NoError(t, expectedSig.FromHex(test.sig))
+
+		assert.NoError(t, sk.VerifyKey(&vk))
+		assert.True(t, vk.Equals(expected))
+
+		sk.Sign([]byte(test.msg), &sig)
+		assert.Equal(t, test.sig, sig.ToHex())
+		assert.True(t, vk.Verify([]byte(test.msg), &sig))
+
+		assert.False(t, vk.Verify([]byte(test.msg+"x"), &sig))
+		sig[0] ^= 0xFF
+		assert.False(t, vk.Verify([]byte(test.msg), &sig))
+	}
+}
+
+func TestSigningSerialization(t *testing.T) {
+	t.Parallel()
+
+	var sk, skOut ppk.SigningKey
+	var vk, vkOut ppk.VerifyKey
+
+	assert.True(t, sk.IsZero())
+	assert.NoError(t, ppk.NewSigningKey(&sk))
+	assert.False(t, sk.IsZero())
+	assert.NoError(t, sk.VerifyKey(&vk))
+
+	assert.NoError(t, skOut.FromHex(sk.ToHex()))
+	assert.True(t, sk.Equals(skOut))
+	assert.NoError(t, vkOut.FromHex(vk.ToHex()))
+	assert.True(t, vk.Equals(vkOut))
+
+	skOut, vkOut = ppk.SigningKey{}, ppk.VerifyKey{}
+	assert.NoError(t, skOut.FromMnemonic(sk.ToMnemonic()))
+	assert.True(t, sk.Equals(skOut))
+	assert.NoError(t, vkOut.FromMnemonic(vk.ToMnemonic()))
+	assert.True(t, vk.Equals(vkOut))
+
+	assert.Error(t, ppk.NewSigningKey(nil))
+	assert.Error(t, sk.VerifyKey(nil))
+	assert.Error(t, skOut.FromHex("00"))
+}
+
+func TestSigningConversion(t *testing.T) {
+	t.Parallel()
+
+	for iter := 0; iter < 64; iter++ {
+		var sk ppk.SigningKey
+		var vk ppk.VerifyKey
+		assert.NoError(t, ppk.NewSigningKey(&sk))
+		assert.NoError(t, sk.VerifyKey(&vk))
+
+		var dh ppk.PrivateKey
+		var fromPrivate, fromVerify ppk.PublicKey
+		assert.NoError(t, sk.PrivateKey(&dh))
+		assert.NoError(t, dh.PublicKey(&fromPrivate))
+		assert.NoError(t, vk.PublicKey(&fromVerify))
+
+		assert.True(t, fromPrivate.Equals(fromVerify))
+	}
+
+	// the converted keys agree on a shared secret
+	var alice, bob ppk.SigningKey
+	var aliceVK, bobVK ppk.VerifyKey
+	assert.NoError(t, alice.FromHex(signingVectors[0].seed))
+	assert.NoError(t, bob.FromHex(signingVectors[1].seed))
+	assert.NoError(t, alice.VerifyKey(&aliceVK))
+	assert.NoError(t, bob.VerifyKey(&bobVK))
+
+	var aliceDH, bobDH ppk.PrivateKey
+	var alicePK, bobPK ppk.PublicKey
+	assert.NoError(t, alice.PrivateKey(&aliceDH))
+	assert.NoError(t, bob.PrivateKey(&bobDH))
+	assert.NoError(t, aliceVK.PublicKey(&alicePK))
+	assert.NoError(t, bobVK.PublicKey(&bobPK))
+
+	var ss0, ss1 [ppk.KeySize]byte
+	assert.NoError(t, aliceDH.SharedSecret(&bobPK, &ss0))
+	assert.NoError(t, bobDH.SharedSecret(&alicePK, &ss1))
+	assert.Equal(t, ss0, ss1)
+
+	// the identity point (y = 1) has no Montgomery form
+	var identity ppk.VerifyKey
+	identity[0] = 1
+	var pk ppk.PublicKey
+	assert.Error(t, identity.PublicKey(&pk))
+	assert.Error(t, aliceVK.PublicKey(nil))
+	assert.Error(t, alice.PrivateKey(nil))
+}
+
+func TestSigningBinding(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.SigningKey
+	var vk ppk.VerifyKey
+	assert.NoError(t, ppk.NewSigningKey(&sk))
+	assert.NoError(t, sk.VerifyKey(&vk))
+
+	var static ppk.PrivateKey
+	var staticPK, otherPK ppk.PublicKey
+	assert.NoError(t, ppk.NewPrivateKey(&static))
+	assert.NoError(t, static.PublicKey(&staticPK))
+	otherPK = staticPK
+	otherPK[0] ^= 0xFF
+
+	var sig ppk.Signature
+	sk.Bind(&staticPK, &sig)
+	assert.True(t, vk.VerifyBinding(&staticPK, &sig))
+	assert.False(t, vk.VerifyBinding(&otherPK, &sig))
+
+	// a binding is not a plain signature of the key
+	assert.False(t, vk.Verify(staticPK[:], &sig))
+}
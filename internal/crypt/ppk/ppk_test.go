@@ -0,0 +1,53 @@
+package ppk_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// points of small order, all of which result in an all-zero shared secret
+var lowOrderPoints = []string{
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"0100000000000000000000000000000000000000000000000000000000000000",
+	"e0eb7a7c3b41b8ae1656e3faf19fc46ada098deb9c32b1fd866205165f49b800",
+	"5f9c95bca3508c24b1d0b1559c83ef5b04445cc4581c8e86d8224eddd09f1157",
+	"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	"edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	"eeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+}
+
+func TestSharedSecret(t *testing.T) {
+	t.Parallel()
+
+	var sk0, sk1 ppk.PrivateKey
+	var pk0, pk1 ppk.PublicKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk0))
+	assert.NoError(t, ppk.NewPrivateKey(&sk1))
+	assert.NoError(t, sk0.PublicKey(&pk0))
+	assert.NoError(t, sk1.PublicKey(&pk1))
+
+	var ss0, ss1 [ppk.KeySize]byte
+	assert.NoError(t, sk0.SharedSecret(&pk1, &ss0))
+	assert.NoError(t, sk1.SharedSecret(&pk0, &ss1))
+	assert.Equal(t, ss0, ss1)
+	assert.NotEqual(t, [ppk.KeySize]byte{}, ss0)
+}
+
+func TestSharedSecretLowOrder(t *testing.T) {
+	t.Parallel()
+
+	var sk ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sk))
+
+	for _, point := range lowOrderPoints {
+		var pk ppk.PublicKey
+		assert.NoError(t, pk.FromHex(point))
+
+		ss := [ppk.KeySize]byte{0xFF}
+		assert.Equal(t, ppk.ErrLowOrderPoint, sk.SharedSecret(&pk, &ss), point)
+		assert.Equal(t, [ppk.KeySize]byte{}, ss, point)
+	}
+}
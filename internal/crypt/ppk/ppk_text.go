@@ -0,0 +1,52 @@
+package ppk
+
+import (
+	"cpl.li/go/cryptor/internal/crypt/codec"
+)
+
+// TextEncoding is the encoding used by MarshalText for keys.
+const TextEncoding = codec.Base64
+
+// Encode returns the key using the given text encoding.
+func (pk *PublicKey) Encode(enc codec.Encoding) string {
+	return enc.Encode(pk[:])
+}
+
+// Decode sets the key from src using the given text encoding.
+func (pk *PublicKey) Decode(enc codec.Encoding, src string) error {
+	return enc.Decode(pk[:], src)
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the key as base64.
+// It has a value receiver so keys embedded by value are encoded too.
+func (pk PublicKey) MarshalText() ([]byte, error) {
+	return []byte(TextEncoding.Encode(pk[:])), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting a key in any
+// of the codec encodings.
+func (pk *PublicKey) UnmarshalText(text []byte) error {
+	return codec.DecodeAny(pk[:], string(text))
+}
+
+// Encode returns the key using the given text encoding.
+func (sk *PrivateKey) Encode(enc codec.Encoding) string {
+	return enc.Encode(sk[:])
+}
+
+// Decode sets the key from src using the given text encoding.
+func (sk *PrivateKey) Decode(enc codec.Encoding, src string) error {
+	return enc.Decode(sk[:], src)
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the key as base64.
+// It has a value receiver so keys embedded by value are encoded too.
+func (sk PrivateKey) MarshalText() ([]byte, error) {
+	return []byte(TextEncoding.Encode(sk[:])), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting a key in any
+// of the codec encodings.
+func (sk *PrivateKey) UnmarshalText(text []byte) error {
+	return codec.DecodeAny(sk[:], string(text))
+}
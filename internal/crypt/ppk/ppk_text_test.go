@@ -0,0 +1,81 @@
+package ppk_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+const (
+	testPrivateKey = "28df0b93627d5b50ed4fef574e774a00ac634cbd3395d0a57e769581e806f82f"
+	testPublicKey  = "a5f686a01f0327c2a1bce2d2ae01c4174d1637fd31a5a065d0b235ea37cc3d74"
+)
+
+func TestKeyEncodings(t *testing.T) {
+	t.Parallel()
+
+	var sk, skOut ppk.PrivateKey
+	var pk, pkOut ppk.PublicKey
+	assert.NoError(t, sk.FromHex(testPrivateKey))
+	assert.NoError(t, pk.FromHex(testPublicKey))
+
+	for _, enc := range []codec.Encoding{
+		codec.Hex, codec.Base64, codec.Base64URL, codec.Base32, codec.Base58,
+	} {
+		assert.NoError(t, skOut.Decode(enc, sk.Encode(enc)))
+		assert.True(t, sk.Equals(skOut), enc)
+
+		assert.NoError(t, pkOut.Decode(enc, pk.Encode(enc)))
+		assert.True(t, pk.Equals(pkOut), enc)
+
+		// text unmarshaling detects the encoding
+		pkOut = ppk.PublicKey{}
+		assert.NoError(t, pkOut.UnmarshalText([]byte(pk.Encode(enc))))
+		assert.True(t, pk.Equals(pkOut), enc)
+	}
+
+	assert.Equal(t, testPublicKey, pk.Encode(codec.Hex))
+	assert.Equal(t, "pfaGoB8DJ8KhvOLSrgHEF00WN/0xpaBl0LI16jfMPXQ=",
+		pk.Encode(codec.Base64))
+	assert.Equal(t, "CArJLPyzshUHKVr8RMEMf84sLxaopJW12DiiuousL9Q7",
+		pk.Encode(codec.Base58))
+
+	assert.Error(t, pkOut.Decode(codec.Base64, "short"))
+	assert.Error(t, pkOut.UnmarshalText([]byte("not a key")))
+}
+
+func TestKeyJSON(t *testing.T) {
+	t.Parallel()
+
+	type identity struct {
+		Private ppk.PrivateKey
+		Public  ppk.PublicKey
+		Peer    *ppk.PublicKey
+	}
+
+	var in, out identity
+	assert.NoError(t, in.Private.FromHex(testPrivateKey))
+	assert.NoError(t, in.Public.FromHex(testPublicKey))
+	in.Peer = &in.Public
+
+	data, err := json.Marshal(in)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Private": "KN8Lk2J9W1DtT+9XTndKAKxjTL0zldClfnaVgegG+C8=",
+		"Public": "pfaGoB8DJ8KhvOLSrgHEF00WN/0xpaBl0LI16jfMPXQ=",
+		"Peer": "pfaGoB8DJ8KhvOLSrgHEF00WN/0xpaBl0LI16jfMPXQ="
+	}`, string(data))
+
+	assert.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	assert.NoError(t, json.Unmarshal(
+		[]byte(`{"Public": "`+testPublicKey+`"}`), &out))
+	assert.True(t, in.Public.Equals(out.Public))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Public": "invalid"}`), &out))
+}
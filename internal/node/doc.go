@@ -0,0 +1 @@
+package node // import "cpl.li/go/cryptor/internal/node"
@@ -0,0 +1,17 @@
+package node
+
+import "errors"
+
+var (
+	// ErrNodeClosed is returned when using a closed node.
+	ErrNodeClosed = errors.New("node closed")
+
+	// ErrUnknownPeer is returned when the peer is not in the node peer table.
+	ErrUnknownPeer = errors.New("unknown peer")
+
+	// ErrNoSession is returned when sending to a peer without a session.
+	ErrNoSession = errors.New("no session with peer")
+
+	// ErrHandshakeTimeout is returned when a handshake got no response.
+	ErrHandshakeTimeout = errors.New("handshake timeout")
+)
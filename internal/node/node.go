@@ -0,0 +1,462 @@
+package node
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+	"cpl.li/go/cryptor/internal/noise"
+)
+
+const (
+	// HandshakeTimeout is how long Connect waits for a handshake response
+	// before sending a new initiation.
+	HandshakeTimeout = 5 * time.Second
+
+	// HandshakeAttempts is how many initiations Connect sends before giving
+	// up on a peer.
+	HandshakeAttempts = 3
+
+	// UnderLoadInitiations is the number of initiations per second after
+	// which a node requires senders to prove their address with a cookie.
+	UnderLoadInitiations = 64
+
+	maxPacketSize = 65535
+	queueSize     = 256
+	timerInterval = time.Second
+)
+
+// Packet is a decrypted transport message received from a peer.
+type Packet struct {
+	Peer ppk.PublicKey
+	Data []byte
+}
+
+// Node is a Cryptor peer bound to a UDP socket. It answers handshakes from
+// the peers in its table, initiates handshakes with Connect and moves data
+// over the resulting sessions with Send and Recv.
+type Node struct {
+	sec ppk.PrivateKey
+	pub ppk.PublicKey
+
+	conn  *net.UDPConn
+	clock noise.Clock
+
+	peers      *noise.PeerTable
+	timestamps noise.TimestampFilter
+	cookies    *noise.CookieChecker
+
+	lock        sync.Mutex
+	pending     map[uint32]*pending
+	sessions    map[uint32]*remote
+	remotes     map[ppk.PublicKey]*remote
+	unconfirmed map[ppk.PublicKey]*remote
+	generators  map[ppk.PublicKey]*noise.CookieGenerator
+
+	rekeying map[ppk.PublicKey]bool
+
+	initiations    int32
+	underLoadAfter int32
+
+	outbound chan outbound
+	recv     chan Packet
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+}
+
+type outbound struct {
+	data []byte
+	addr *net.UDPAddr
+}
+
+// remote is an established session with a peer.
+type remote struct {
+	peer        *noise.Peer
+	session     *noise.Session
+	addr        *net.UDPAddr
+	localIndex  uint32
+	remoteIndex uint32
+	initiator   bool
+}
+
+// pending is a handshake initiated by Connect, waiting for a response.
+type pending struct {
+	peer      *noise.Peer
+	handshake *noise.Handshake
+	addr      *net.UDPAddr
+	index     uint32
+
+	done  chan struct{}
+	retry chan struct{}
+}
+
+// New returns a node using the given static private key, listening for UDP
+// packets on the given address.
+func New(sec *ppk.PrivateKey, addr string) (*Node, error) {
+	return newNode(sec, addr, noise.SystemClock)
+}
+
+// newNode returns a node measuring session lifetimes with the clock.
+func newNode(sec *ppk.PrivateKey, addr string, clock noise.Clock) (*Node, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		sec:            *sec,
+		conn:           conn,
+		clock:          clock,
+		peers:          noise.NewPeerTableWithPolicy(&noise.DefaultSessionPolicy, clock),
+		pending:        make(map[uint32]*pending),
+		sessions:       make(map[uint32]*remote),
+		remotes:        make(map[ppk.PublicKey]*remote),
+		unconfirmed:    make(map[ppk.PublicKey]*remote),
+		generators:     make(map[ppk.PublicKey]*noise.CookieGenerator),
+		rekeying:       make(map[ppk.PublicKey]bool),
+		underLoadAfter: UnderLoadInitiations,
+		outbound:       make(chan outbound, queueSize),
+		recv:           make(chan Packet, queueSize),
+		closed:         make(chan struct{}),
+	}
+
+	if err := n.sec.PublicKey(&n.pub); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	n.cookies = noise.NewCookieCheckerWithClock(&n.pub, n.clock)
+
+	n.wg.Add(3)
+	go n.receiveLoop()
+	go n.sendLoop()
+	go n.timerLoop()
+
+	return n, nil
+}
+
+// Close stops the node and closes its socket. The Recv channel is closed once
+// all goroutines have stopped.
+func (n *Node) Close() error {
+	err := ErrNodeClosed
+
+	n.closeOnce.Do(func() {
+		close(n.closed)
+		err = n.conn.Close()
+		n.wg.Wait()
+		close(n.recv)
+	})
+
+	return err
+}
+
+// Addr returns the local address the node is listening on.
+func (n *Node) Addr() *net.UDPAddr {
+	return n.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// PublicKey returns the static public key of the node.
+func (n *Node) PublicKey() ppk.PublicKey {
+	return n.pub
+}
+
+// Peers returns the table of peers the node accepts handshakes from. Peers
+// added to it follow the node session policy.
+func (n *Node) Peers() *noise.PeerTable {
+	return n.peers
+}
+
+// AddPeer allows the peer with the given public key and optional preshared
+// key to perform handshakes with the node.
+func (n *Node) AddPeer(pub *ppk.PublicKey, psk *[ppk.KeySize]byte) error {
+	return n.peers.Add(noise.NewPeer(pub, psk))
+}
+
+// SetSessionPolicy sets the lifetime limits of the sessions established from
+// now on, and how long the keyrings of all peers keep replaced sessions.
+func (n *Node) SetSessionPolicy(policy *noise.SessionPolicy) {
+	n.peers.SetPolicy(policy)
+}
+
+// Recv returns the channel decrypted packets from peers are delivered on.
+// Packets are dropped if the channel is full. Empty packets are keepalives and
+// are never delivered.
+func (n *Node) Recv() <-chan Packet {
+	return n.recv
+}
+
+// Connect performs a handshake with the peer at the given address, blocking
+// until a session is established or all attempts timed out.
+func (n *Node) Connect(pub ppk.PublicKey, addr *net.UDPAddr) error {
+	peer := n.peers.Lookup(&pub)
+	if peer == nil {
+		return ErrUnknownPeer
+	}
+
+	for attempt := 0; attempt < HandshakeAttempts; attempt++ {
+		p, data, err := n.initiate(peer, addr)
+		if err != nil {
+			return err
+		}
+
+		if err := n.queue(data, addr); err != nil {
+			n.dropPending(p.index)
+			return err
+		}
+
+		select {
+		case <-p.done:
+			return nil
+		case <-p.retry:
+		case <-time.After(HandshakeTimeout):
+		case <-n.closed:
+			return ErrNodeClosed
+		}
+
+		n.dropPending(p.index)
+	}
+
+	return ErrHandshakeTimeout
+}
+
+// Send seals the data in the active session with the peer and sends it.
+func (n *Node) Send(pub ppk.PublicKey, data []byte) error {
+	n.lock.Lock()
+	r, ok := n.remotes[pub]
+	n.lock.Unlock()
+	if !ok {
+		return ErrNoSession
+	}
+
+	return n.seal(r, data)
+}
+
+// seal seals the data in the session of the remote and queues it.
+func (n *Node) seal(r *remote, data []byte) error {
+	packet, err := r.session.Seal(data)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	msg := noise.MessageTransport{
+		Receiver: r.remoteIndex,
+		Packet:   packet,
+	}
+	addr := r.addr
+	n.lock.Unlock()
+
+	encoded, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return n.queue(encoded, addr)
+}
+
+func (n *Node) queue(data []byte, addr *net.UDPAddr) error {
+	select {
+	case n.outbound <- outbound{data: data, addr: addr}:
+		return nil
+	case <-n.closed:
+		return ErrNodeClosed
+	}
+}
+
+func (n *Node) deliver(packet Packet) {
+	select {
+	case n.recv <- packet:
+	default:
+	}
+}
+
+func (n *Node) receiveLoop() {
+	defer n.wg.Done()
+
+	buffer := make([]byte, maxPacketSize)
+
+	for {
+		size, addr, err := n.conn.ReadFromUDP(buffer)
+		if err != nil {
+			select {
+			case <-n.closed:
+				return
+			default:
+				continue
+			}
+		}
+
+		n.handle(buffer[:size], addr)
+	}
+}
+
+func (n *Node) sendLoop() {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case out := <-n.outbound:
+			n.conn.WriteToUDP(out.data, out.addr)
+		case <-n.closed:
+			return
+		}
+	}
+}
+
+func (n *Node) timerLoop() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(timerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			atomic.StoreInt32(&n.initiations, 0)
+			n.maintain()
+		case <-n.closed:
+			return
+		}
+	}
+}
+
+// maintain drops sessions no longer held by their peer keyring, or expired
+// before being confirmed, and starts a new handshake for sessions this node
+// initiated which need a rekey.
+func (n *Node) maintain() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for index, r := range n.sessions {
+		pub := r.peer.PublicKey()
+		if n.unconfirmed[pub] == r && !r.session.Expired() {
+			continue
+		}
+
+		keyring := r.peer.Keyring()
+		if r.session == keyring.Current() || r.session == keyring.Previous() {
+			continue
+		}
+
+		delete(n.sessions, index)
+		if n.remotes[pub] == r {
+			delete(n.remotes, pub)
+		}
+		if n.unconfirmed[pub] == r {
+			delete(n.unconfirmed, pub)
+		}
+	}
+
+	for pub, r := range n.remotes {
+		if !r.initiator || n.rekeying[pub] || !r.session.NeedsRekey() {
+			continue
+		}
+
+		n.rekeying[pub] = true
+		n.wg.Add(1)
+		go n.rekey(pub, r.addr)
+	}
+}
+
+func (n *Node) rekey(pub ppk.PublicKey, addr *net.UDPAddr) {
+	defer n.wg.Done()
+
+	n.Connect(pub, addr)
+
+	n.lock.Lock()
+	delete(n.rekeying, pub)
+	n.lock.Unlock()
+}
+
+func (n *Node) newSession(hs *noise.Handshake) (*noise.Session, error) {
+	policy := n.peers.Policy()
+
+	return noise.NewSessionWithPolicy(hs, &policy, n.clock)
+}
+
+func (n *Node) underLoad() bool {
+	return atomic.AddInt32(&n.initiations, 1) > atomic.LoadInt32(&n.underLoadAfter)
+}
+
+func (n *Node) generator(pub *ppk.PublicKey) *noise.CookieGenerator {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	gen, ok := n.generators[*pub]
+	if !ok {
+		gen = noise.NewCookieGeneratorWithClock(pub, n.clock)
+		n.generators[*pub] = gen
+	}
+
+	return gen
+}
+
+// newIndex returns a random index not used by any pending handshake or
+// session. Must be called with the node lock held.
+func (n *Node) newIndex() uint32 {
+	for {
+		index := uint32(crypt.RandomUint64())
+		if _, ok := n.pending[index]; ok {
+			continue
+		}
+		if _, ok := n.sessions[index]; ok {
+			continue
+		}
+
+		return index
+	}
+}
+
+func (n *Node) dropPending(index uint32) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delete(n.pending, index)
+}
+
+// register makes the session the active one for its peer. The replaced
+// session keeps its index until dropped from the peer keyring. Must be called
+// with the node lock held, so maintain never sees a session missing from the
+// peer keyring.
+func (n *Node) register(r *remote) {
+	pub := r.peer.PublicKey()
+
+	r.peer.SetSession(r.session)
+	n.remotes[pub] = r
+	n.sessions[r.localIndex] = r
+}
+
+// accept registers a session this node responded to for receiving only. The
+// peer may not have the session yet, so packets are still sent using the
+// active one until confirm is called. Must be called with the node lock held.
+func (n *Node) accept(r *remote) {
+	pub := r.peer.PublicKey()
+
+	if old, ok := n.unconfirmed[pub]; ok {
+		delete(n.sessions, old.localIndex)
+	}
+	n.unconfirmed[pub] = r
+	n.sessions[r.localIndex] = r
+}
+
+// confirm makes an accepted session the active one once the peer used it,
+// unless it was replaced by a newer one. Must be called with the node lock
+// held.
+func (n *Node) confirm(r *remote) {
+	pub := r.peer.PublicKey()
+	if n.unconfirmed[pub] != r {
+		return
+	}
+
+	delete(n.unconfirmed, pub)
+	n.register(r)
+}
@@ -0,0 +1,262 @@
+package node
+
+import (
+	"net"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+	"cpl.li/go/cryptor/internal/noise"
+)
+
+// initiate prepares a handshake initiation for the peer and records it as
+// pending, returning the encoded message.
+func (n *Node) initiate(peer *noise.Peer, addr *net.UDPAddr) (*pending, []byte, error) {
+	var (
+		msg noise.MessageInitiation
+		hs  = new(noise.Handshake)
+		pub = peer.PublicKey()
+		psk = peer.PresharedKey()
+		ts  = noise.NewTimestamp(time.Now())
+	)
+
+	if err := hs.SetPresharedKey(&psk); err != nil {
+		return nil, nil, err
+	}
+	if err := hs.InitializeSender(&pub); err != nil {
+		return nil, nil, err
+	}
+	if err := hs.Exchange(&n.pub, &msg.Static); err != nil {
+		return nil, nil, err
+	}
+	if err := hs.ExchangeTimestamp(&n.sec, &pub, &ts, &msg.Timestamp); err != nil {
+		return nil, nil, err
+	}
+
+	p := &pending{
+		peer:      peer,
+		handshake: hs,
+		addr:      addr,
+		done:      make(chan struct{}),
+		retry:     make(chan struct{}, 1),
+	}
+
+	n.lock.Lock()
+	p.index = n.newIndex()
+	n.pending[p.index] = p
+	n.lock.Unlock()
+
+	msg.Sender = p.index
+	msg.Ephemeral = hs.PublicKey()
+
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		n.dropPending(p.index)
+		return nil, nil, err
+	}
+	n.generator(&pub).AddMACs(data)
+
+	return p, data, nil
+}
+
+// handle dispatches a packet received from the socket. Invalid packets are
+// dropped without any response.
+func (n *Node) handle(data []byte, addr *net.UDPAddr) {
+	if len(data) == 0 {
+		return
+	}
+
+	switch data[0] {
+	case noise.MessageTypeInitiation:
+		n.handleInitiation(data, addr)
+	case noise.MessageTypeResponse:
+		n.handleResponse(data, addr)
+	case noise.MessageTypeCookieReply:
+		n.handleCookieReply(data)
+	case noise.MessageTypeTransport:
+		n.handleTransport(data, addr)
+	}
+}
+
+func (n *Node) handleInitiation(data []byte, addr *net.UDPAddr) {
+	var msg noise.MessageInitiation
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return
+	}
+	if !n.cookies.CheckMAC1(data) {
+		return
+	}
+
+	src := []byte(addr.String())
+	if n.underLoad() && !n.cookies.CheckMAC2(data, src) {
+		reply, err := n.cookies.CreateReply(data, msg.Sender, src)
+		if err != nil {
+			return
+		}
+		encoded, err := reply.MarshalBinary()
+		if err != nil {
+			return
+		}
+		n.queue(encoded, addr)
+
+		return
+	}
+
+	var (
+		hs   = new(noise.Handshake)
+		sPub ppk.PublicKey
+		ts   noise.Timestamp
+		resp noise.MessageResponse
+	)
+
+	if err := hs.SetPeerTable(n.peers); err != nil {
+		return
+	}
+	if err := hs.SetTimestampFilter(&n.timestamps); err != nil {
+		return
+	}
+	if err := hs.InitializeRecipient(&n.sec, &msg.Ephemeral); err != nil {
+		return
+	}
+	if err := hs.Exchange(&sPub, &msg.Static); err != nil {
+		return
+	}
+	if err := hs.ExchangeTimestamp(&n.sec, &sPub, &ts, &msg.Timestamp); err != nil {
+		return
+	}
+	if err := hs.PrepareRecipientResponse(&msg.Ephemeral, &sPub, &resp.Empty); err != nil {
+		return
+	}
+	resp.Ephemeral = hs.PublicKey()
+
+	session, err := n.newSession(hs)
+	if err != nil {
+		return
+	}
+
+	r := &remote{
+		peer:        hs.Peer(),
+		session:     session,
+		addr:        addr,
+		remoteIndex: msg.Sender,
+	}
+
+	n.lock.Lock()
+	r.localIndex = n.newIndex()
+	n.accept(r)
+	n.lock.Unlock()
+
+	resp.Sender = r.localIndex
+	resp.Receiver = msg.Sender
+
+	encoded, err := resp.MarshalBinary()
+	if err != nil {
+		return
+	}
+	n.generator(&sPub).AddMACs(encoded)
+
+	n.queue(encoded, addr)
+}
+
+func (n *Node) handleResponse(data []byte, addr *net.UDPAddr) {
+	var msg noise.MessageResponse
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return
+	}
+	if !n.cookies.CheckMAC1(data) {
+		return
+	}
+
+	n.lock.Lock()
+	p, ok := n.pending[msg.Receiver]
+	n.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	err := p.handshake.ConsumeRecipientResponse(&n.sec, &msg.Ephemeral, &msg.Empty)
+	if err != nil {
+		return
+	}
+
+	session, err := n.newSession(p.handshake)
+	if err != nil {
+		return
+	}
+
+	r := &remote{
+		peer:        p.peer,
+		session:     session,
+		addr:        addr,
+		localIndex:  p.index,
+		remoteIndex: msg.Sender,
+		initiator:   true,
+	}
+
+	n.lock.Lock()
+	delete(n.pending, p.index)
+	n.register(r)
+	n.lock.Unlock()
+
+	// the responder only starts using the session once it receives a packet
+	// sealed in it
+	n.seal(r, nil)
+
+	close(p.done)
+}
+
+func (n *Node) handleCookieReply(data []byte) {
+	var msg noise.MessageCookieReply
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return
+	}
+
+	n.lock.Lock()
+	p, ok := n.pending[msg.Receiver]
+	n.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	pub := p.peer.PublicKey()
+	if err := n.generator(&pub).ConsumeReply(&msg); err != nil {
+		return
+	}
+
+	select {
+	case p.retry <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) handleTransport(data []byte, addr *net.UDPAddr) {
+	var msg noise.MessageTransport
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return
+	}
+
+	n.lock.Lock()
+	r, ok := n.sessions[msg.Receiver]
+	n.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	plaintext, err := r.session.Open(msg.Packet)
+	if err != nil {
+		return
+	}
+
+	n.lock.Lock()
+	r.addr = addr
+	n.confirm(r)
+	n.lock.Unlock()
+
+	if len(plaintext) == 0 {
+		return
+	}
+
+	n.deliver(Packet{
+		Peer: r.peer.PublicKey(),
+		Data: plaintext,
+	})
+}
@@ -0,0 +1,271 @@
+package node
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+	"cpl.li/go/cryptor/internal/noise"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1586000000, 0)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	fc.now = fc.now.Add(d)
+}
+
+func newTestNode(t *testing.T) *Node {
+	return newTestNodeWithClock(t, noise.SystemClock)
+}
+
+func newTestNodeWithClock(t *testing.T, clock noise.Clock) *Node {
+	var sec ppk.PrivateKey
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+
+	n, err := newNode(&sec, "127.0.0.1:0", clock)
+	assert.NoError(t, err)
+
+	return n
+}
+
+func nodePair(t *testing.T, psk *[ppk.KeySize]byte) (n0, n1 *Node) {
+	return nodePairWithClock(t, psk, noise.SystemClock)
+}
+
+func nodePairWithClock(t *testing.T, psk *[ppk.KeySize]byte, clock noise.Clock) (n0, n1 *Node) {
+	n0 = newTestNodeWithClock(t, clock)
+	n1 = newTestNodeWithClock(t, clock)
+
+	pub0 := n0.PublicKey()
+	pub1 := n1.PublicKey()
+	assert.NoError(t, n0.AddPeer(&pub1, psk))
+	assert.NoError(t, n1.AddPeer(&pub0, psk))
+
+	return n0, n1
+}
+
+func recvPacket(t *testing.T, n *Node) Packet {
+	select {
+	case packet := <-n.Recv():
+		return packet
+	case <-time.After(HandshakeTimeout):
+		t.Fatal("timed out waiting for packet")
+	}
+
+	return Packet{}
+}
+
+func TestNodeConnectSend(t *testing.T) {
+	t.Parallel()
+
+	var psk [ppk.KeySize]byte
+	copy(psk[:], crypt.RandomBytes(ppk.KeySize))
+
+	n0, n1 := nodePair(t, &psk)
+	defer n0.Close()
+	defer n1.Close()
+
+	pub0 := n0.PublicKey()
+	pub1 := n1.PublicKey()
+
+	assert.Equal(t, ErrNoSession, n0.Send(pub1, []byte("too early")))
+
+	assert.NoError(t, n0.Connect(pub1, n1.Addr()))
+	assert.NotNil(t, n0.Peers().Lookup(&pub1).Session())
+
+	msg := []byte("We attack at dawn")
+
+	assert.NoError(t, n0.Send(pub1, msg))
+	packet := recvPacket(t, n1)
+	assert.Equal(t, pub0, packet.Peer)
+	assert.Equal(t, msg, packet.Data)
+
+	// recipient replies over the same session
+	assert.NotNil(t, n1.Peers().Lookup(&pub0).Session())
+	assert.NoError(t, n1.Send(pub0, msg))
+	packet = recvPacket(t, n0)
+	assert.Equal(t, pub1, packet.Peer)
+	assert.Equal(t, msg, packet.Data)
+
+	// reconnecting replaces the session
+	assert.NoError(t, n1.Connect(pub0, n0.Addr()))
+	assert.NoError(t, n0.Send(pub1, msg))
+	assert.Equal(t, msg, recvPacket(t, n1).Data)
+	assert.NoError(t, n1.Send(pub0, msg))
+	assert.Equal(t, msg, recvPacket(t, n0).Data)
+}
+
+func TestNodeUnderLoad(t *testing.T) {
+	t.Parallel()
+
+	n0, n1 := nodePair(t, nil)
+	defer n0.Close()
+	defer n1.Close()
+
+	// every initiation requires a cookie
+	atomic.StoreInt32(&n1.underLoadAfter, -1)
+
+	pub1 := n1.PublicKey()
+	assert.NoError(t, n0.Connect(pub1, n1.Addr()))
+
+	assert.NoError(t, n0.Send(pub1, []byte("We attack at dawn")))
+	assert.Equal(t, []byte("We attack at dawn"), recvPacket(t, n1).Data)
+}
+
+func TestNodeUnknownPeer(t *testing.T) {
+	t.Parallel()
+
+	n0 := newTestNode(t)
+	n1 := newTestNode(t)
+	defer n0.Close()
+	defer n1.Close()
+
+	pub1 := n1.PublicKey()
+	assert.Equal(t, ErrUnknownPeer, n0.Connect(pub1, n1.Addr()))
+}
+
+func TestNodeClose(t *testing.T) {
+	t.Parallel()
+
+	n := newTestNode(t)
+	assert.NoError(t, n.Close())
+	assert.Equal(t, ErrNodeClosed, n.Close())
+
+	_, ok := <-n.Recv()
+	assert.False(t, ok)
+}
+
+func TestNodeRekey(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	n0, n1 := nodePairWithClock(t, nil, clock)
+	defer n0.Close()
+	defer n1.Close()
+
+	pub0 := n0.PublicKey()
+	pub1 := n1.PublicKey()
+	assert.NoError(t, n0.Connect(pub1, n1.Addr()))
+
+	peer := n0.Peers().Lookup(&pub1)
+	first := peer.Session()
+
+	// not due yet
+	n0.maintain()
+	assert.False(t, rekeying(n0, pub1))
+
+	clock.Advance(noise.RekeyAfterTime)
+	n0.maintain()
+
+	deadline := time.Now().Add(HandshakeTimeout)
+	for rekeying(n0, pub1) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.NotEqual(t, first, peer.Session())
+	assert.Equal(t, first, peer.Keyring().Previous())
+
+	// both sides use the new session
+	assert.NoError(t, n0.Send(pub1, []byte("We attack at dawn")))
+	assert.Equal(t, []byte("We attack at dawn"), recvPacket(t, n1).Data)
+	assert.NoError(t, n1.Send(pub0, []byte("We attack at dusk")))
+	assert.Equal(t, []byte("We attack at dusk"), recvPacket(t, n0).Data)
+
+	// the replaced session is dropped once the keyring no longer keeps it
+	clock.Advance(noise.KeepPreviousTime)
+	n0.maintain()
+	assert.Nil(t, peer.Keyring().Previous())
+	n0.lock.Lock()
+	assert.Len(t, n0.sessions, 1)
+	n0.lock.Unlock()
+
+	// hold back the response to the next initiation, so n1 has a new session
+	// n0 doesn't know about yet
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer relay.Close()
+
+	p, data, err := n0.initiate(peer, n1.Addr())
+	assert.NoError(t, err)
+	n1.handle(data, relay.LocalAddr().(*net.UDPAddr))
+
+	active := n1.Peers().Lookup(&pub0).Session()
+	assert.NoError(t, n1.Send(pub0, []byte("in flight")))
+	assert.Equal(t, []byte("in flight"), recvPacket(t, n0).Data)
+
+	buffer := make([]byte, maxPacketSize)
+	assert.NoError(t, relay.SetReadDeadline(time.Now().Add(HandshakeTimeout)))
+	size, _, err := relay.ReadFromUDP(buffer)
+	assert.NoError(t, err)
+	n0.handle(buffer[:size], n1.Addr())
+
+	select {
+	case <-p.done:
+	default:
+		t.Fatal("handshake not completed")
+	}
+
+	// n1 switches to the new session once n0 used it
+	assert.NoError(t, n0.Send(pub1, []byte("We attack at noon")))
+	assert.Equal(t, []byte("We attack at noon"), recvPacket(t, n1).Data)
+	assert.NotEqual(t, active, n1.Peers().Lookup(&pub0).Session())
+	assert.NoError(t, n1.Send(pub0, []byte("We attack at midnight")))
+	assert.Equal(t, []byte("We attack at midnight"), recvPacket(t, n0).Data)
+}
+
+func TestNodeSessionPolicy(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	n0 := newTestNodeWithClock(t, clock)
+	n1 := newTestNodeWithClock(t, clock)
+	defer n0.Close()
+	defer n1.Close()
+
+	policy := noise.DefaultSessionPolicy
+	policy.KeepPreviousTime = time.Second
+	n0.SetSessionPolicy(&policy)
+
+	// peers added through the table follow the node policy
+	pub0 := n0.PublicKey()
+	pub1 := n1.PublicKey()
+	assert.NoError(t, n0.Peers().Add(noise.NewPeer(&pub1, nil)))
+	assert.NoError(t, n1.AddPeer(&pub0, nil))
+
+	assert.NoError(t, n0.Connect(pub1, n1.Addr()))
+	assert.NoError(t, n0.Connect(pub1, n1.Addr()))
+
+	keyring := n0.Peers().Lookup(&pub1).Keyring()
+	assert.NotNil(t, keyring.Previous())
+	clock.Advance(time.Second)
+	assert.Nil(t, keyring.Previous())
+}
+
+func rekeying(n *Node, pub ppk.PublicKey) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.rekeying[pub]
+}
@@ -0,0 +1,18 @@
+package noise
+
+import "time"
+
+// Clock is the source of time for session and cookie lifetimes, allowing
+// tests to control it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock backed by time.Now.
+var SystemClock Clock = systemClock{}
@@ -0,0 +1,213 @@
+package noise
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/hashing"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"golang.org/x/crypto/blake2s"
+	chacha "golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// CookieRefreshTime is how often the recipient rotates the secret its
+	// cookies are derived from, and how long a sender keeps a cookie.
+	CookieRefreshTime = 2 * time.Minute
+
+	cookieSize = 16
+)
+
+var (
+	labelMAC1   = []byte("mac1----")
+	labelCookie = []byte("cookie--")
+)
+
+// CookieChecker is used by a recipient to validate the mac1 and mac2 fields
+// of handshake messages and to issue cookie replies. Checking mac1 only
+// requires a keyed hash, so messages not addressed to the recipient's static
+// key can be dropped before any Diffie-Hellman operation. When under load, a
+// recipient should also require a valid mac2, which proves the sender owns
+// its source address.
+type CookieChecker struct {
+	lock  sync.RWMutex
+	clock Clock
+
+	mac1Key   [blake2s.Size]byte
+	cookieKey [chacha.KeySize]byte
+
+	secret    [blake2s.Size]byte
+	secretSet time.Time
+}
+
+// CookieGenerator is used by a sender to fill in the mac1 and mac2 fields of
+// the handshake messages it sends to a recipient, using the latest cookie
+// received from it.
+type CookieGenerator struct {
+	lock  sync.Mutex
+	clock Clock
+
+	mac1Key   [blake2s.Size]byte
+	cookieKey [chacha.KeySize]byte
+
+	cookie    [cookieSize]byte
+	cookieSet time.Time
+
+	lastMAC1    [MACSize]byte
+	hasLastMAC1 bool
+}
+
+// NewCookieChecker returns a checker for messages addressed to the given
+// recipient static public key.
+func NewCookieChecker(rPub *ppk.PublicKey) *CookieChecker {
+	return NewCookieCheckerWithClock(rPub, SystemClock)
+}
+
+// NewCookieCheckerWithClock returns a checker rotating its secret as measured
+// by the clock.
+func NewCookieCheckerWithClock(rPub *ppk.PublicKey, clock Clock) *CookieChecker {
+	cc := &CookieChecker{clock: clock}
+	hashing.Hash((*hashing.HashSum)(&cc.mac1Key), labelMAC1, rPub[:])
+	hashing.Hash((*hashing.HashSum)(&cc.cookieKey), labelCookie, rPub[:])
+
+	return cc
+}
+
+// CheckMAC1 returns true if the message carries a valid mac1.
+func (cc *CookieChecker) CheckMAC1(msg []byte) bool {
+	if len(msg) < 2*MACSize {
+		return false
+	}
+
+	offsetMAC1 := len(msg) - 2*MACSize
+	offsetMAC2 := len(msg) - MACSize
+
+	return hashing.VerifyMAC(msg[offsetMAC1:offsetMAC2], cc.mac1Key[:],
+		msg[:offsetMAC1])
+}
+
+// CheckMAC2 returns true if the message carries a valid mac2 for the cookie
+// bound to the given source address. Only meaningful once CheckMAC1 passed.
+func (cc *CookieChecker) CheckMAC2(msg, src []byte) bool {
+	if len(msg) < 2*MACSize {
+		return false
+	}
+
+	cc.lock.RLock()
+	defer cc.lock.RUnlock()
+
+	if cc.clock.Now().Sub(cc.secretSet) > CookieRefreshTime {
+		return false
+	}
+
+	cookie, _ := hashing.ShortMAC(cc.secret[:], src)
+
+	offsetMAC2 := len(msg) - MACSize
+
+	return hashing.VerifyMAC(msg[offsetMAC2:], cookie[:], msg[:offsetMAC2])
+}
+
+// CreateReply returns a cookie reply for the given message, addressed to the
+// sender index it came from and bound to its source address.
+func (cc *CookieChecker) CreateReply(msg []byte, receiver uint32, src []byte) (*MessageCookieReply, error) {
+	if len(msg) < 2*MACSize {
+		return nil, ErrInvalidMessageSize
+	}
+
+	cc.lock.Lock()
+	if now := cc.clock.Now(); now.Sub(cc.secretSet) > CookieRefreshTime {
+		if _, err := rand.Read(cc.secret[:]); err != nil {
+			cc.lock.Unlock()
+			return nil, err
+		}
+		cc.secretSet = now
+	}
+
+	cookie, _ := hashing.ShortMAC(cc.secret[:], src)
+	cc.lock.Unlock()
+
+	reply := &MessageCookieReply{Receiver: receiver}
+	if _, err := rand.Read(reply.Nonce[:]); err != nil {
+		return nil, err
+	}
+
+	offsetMAC1 := len(msg) - 2*MACSize
+	offsetMAC2 := len(msg) - MACSize
+
+	cipher, _ := chacha.NewX(cc.cookieKey[:])
+	cipher.Seal(reply.Cookie[:0], reply.Nonce[:], cookie[:],
+		msg[offsetMAC1:offsetMAC2])
+
+	return reply, nil
+}
+
+// NewCookieGenerator returns a generator for messages addressed to the given
+// recipient static public key.
+func NewCookieGenerator(rPub *ppk.PublicKey) *CookieGenerator {
+	return NewCookieGeneratorWithClock(rPub, SystemClock)
+}
+
+// NewCookieGeneratorWithClock returns a generator expiring cookies as
+// measured by the clock.
+func NewCookieGeneratorWithClock(rPub *ppk.PublicKey, clock Clock) *CookieGenerator {
+	cg := &CookieGenerator{clock: clock}
+	hashing.Hash((*hashing.HashSum)(&cg.mac1Key), labelMAC1, rPub[:])
+	hashing.Hash((*hashing.HashSum)(&cg.cookieKey), labelCookie, rPub[:])
+
+	return cg
+}
+
+// ConsumeReply decrypts and stores the cookie carried by the reply. The reply
+// must answer the last message passed to AddMACs.
+func (cg *CookieGenerator) ConsumeReply(reply *MessageCookieReply) error {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+
+	if !cg.hasLastMAC1 {
+		return ErrInvalidCookieReply
+	}
+
+	var cookie [cookieSize]byte
+
+	cipher, _ := chacha.NewX(cg.cookieKey[:])
+	_, err := cipher.Open(cookie[:0], reply.Nonce[:], reply.Cookie[:],
+		cg.lastMAC1[:])
+	if err != nil {
+		return ErrInvalidCookieReply
+	}
+
+	cg.cookie = cookie
+	cg.cookieSet = cg.clock.Now()
+	cg.hasLastMAC1 = false
+
+	return nil
+}
+
+// AddMACs fills in the mac1 and mac2 fields, the last 2*MACSize bytes of the
+// encoded message. The mac2 field is left zero unless a fresh cookie is known.
+func (cg *CookieGenerator) AddMACs(msg []byte) {
+	if len(msg) < 2*MACSize {
+		return
+	}
+
+	offsetMAC1 := len(msg) - 2*MACSize
+	offsetMAC2 := len(msg) - MACSize
+
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+
+	var mac2 [MACSize]byte
+
+	mac1, _ := hashing.ShortMAC(cg.mac1Key[:], msg[:offsetMAC1])
+	copy(msg[offsetMAC1:], mac1[:])
+
+	cg.lastMAC1 = mac1
+	cg.hasLastMAC1 = true
+
+	if !cg.cookieSet.IsZero() && cg.clock.Now().Sub(cg.cookieSet) <= CookieRefreshTime {
+		mac2, _ = hashing.ShortMAC(cg.cookie[:], msg[:offsetMAC2])
+	}
+	copy(msg[offsetMAC2:], mac2[:])
+}
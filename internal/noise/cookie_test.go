@@ -0,0 +1,201 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cookieTestKeys(t *testing.T) (rPub, otherPub ppk.PublicKey) {
+	var sec ppk.PrivateKey
+
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+	assert.NoError(t, sec.PublicKey(&rPub))
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+	assert.NoError(t, sec.PublicKey(&otherPub))
+
+	return rPub, otherPub
+}
+
+func TestCookieMAC1(t *testing.T) {
+	t.Parallel()
+
+	rPub, otherPub := cookieTestKeys(t)
+
+	checker := NewCookieChecker(&rPub)
+	generator := NewCookieGenerator(&rPub)
+	otherGenerator := NewCookieGenerator(&otherPub)
+
+	var msg MessageInitiation
+	msg.Sender = 1
+
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+
+	// no macs
+	assert.False(t, checker.CheckMAC1(data))
+
+	// macs for another recipient
+	otherGenerator.AddMACs(data)
+	assert.False(t, checker.CheckMAC1(data))
+
+	generator.AddMACs(data)
+	assert.True(t, checker.CheckMAC1(data))
+
+	// no cookie yet, mac2 is zero
+	assert.Equal(t, make([]byte, MACSize), data[len(data)-MACSize:])
+
+	// tampered message
+	data[4] ^= 0xFF
+	assert.False(t, checker.CheckMAC1(data))
+
+	assert.False(t, checker.CheckMAC1(nil))
+	assert.False(t, checker.CheckMAC2(nil, nil))
+}
+
+func TestCookieMAC2(t *testing.T) {
+	t.Parallel()
+
+	rPub, _ := cookieTestKeys(t)
+
+	checker := NewCookieChecker(&rPub)
+	generator := NewCookieGenerator(&rPub)
+
+	src := []byte("127.0.0.1:4242")
+
+	var msg MessageInitiation
+	msg.Sender = 1
+
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+
+	generator.AddMACs(data)
+	assert.True(t, checker.CheckMAC1(data))
+	assert.False(t, checker.CheckMAC2(data, src))
+
+	// recipient is under load, replies with a cookie
+	reply, err := checker.CreateReply(data, msg.Sender, src)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Sender, reply.Receiver)
+
+	replyData, err := reply.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, replyData, MessageCookieReplySize)
+
+	var replyOut MessageCookieReply
+	assert.NoError(t, replyOut.UnmarshalBinary(replyData))
+	assert.Equal(t, *reply, replyOut)
+
+	assert.NoError(t, generator.ConsumeReply(&replyOut))
+
+	// the same reply is only accepted once
+	assert.Equal(t, ErrInvalidCookieReply, generator.ConsumeReply(&replyOut))
+
+	// retry with the cookie
+	msg.Sender = 2
+	data, err = msg.MarshalBinary()
+	assert.NoError(t, err)
+
+	generator.AddMACs(data)
+	assert.True(t, checker.CheckMAC1(data))
+	assert.True(t, checker.CheckMAC2(data, src))
+	assert.False(t, checker.CheckMAC2(data, []byte("127.0.0.1:4243")))
+}
+
+func TestCookieExpiry(t *testing.T) {
+	t.Parallel()
+
+	rPub, _ := cookieTestKeys(t)
+	clock := newFakeClock()
+
+	checker := NewCookieCheckerWithClock(&rPub, clock)
+	generator := NewCookieGeneratorWithClock(&rPub, clock)
+
+	src := []byte("127.0.0.1:4242")
+	zero := make([]byte, MACSize)
+
+	var msg MessageInitiation
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+
+	cookieData := func() []byte {
+		generator.AddMACs(data)
+		reply, err := checker.CreateReply(data, 0, src)
+		assert.NoError(t, err)
+		assert.NoError(t, generator.ConsumeReply(reply))
+
+		fresh := append([]byte(nil), data...)
+		generator.AddMACs(fresh)
+		assert.True(t, checker.CheckMAC2(fresh, src))
+
+		return fresh
+	}
+
+	old := cookieData()
+
+	// cookie and secret are still valid right until the refresh time
+	clock.Advance(CookieRefreshTime)
+	generator.AddMACs(data)
+	assert.NotEqual(t, zero, data[len(data)-MACSize:])
+	assert.True(t, checker.CheckMAC2(data, src))
+	assert.True(t, checker.CheckMAC2(old, src))
+
+	// the sender drops the cookie and the recipient rejects its secret
+	clock.Advance(time.Nanosecond)
+	generator.AddMACs(data)
+	assert.Equal(t, zero, data[len(data)-MACSize:])
+	assert.False(t, checker.CheckMAC2(old, src))
+
+	// a new reply rotates the secret, cookies from the old one are rejected
+	fresh := cookieData()
+	assert.NotEqual(t, old[len(old)-MACSize:], fresh[len(fresh)-MACSize:])
+	assert.False(t, checker.CheckMAC2(old, src))
+
+	// the secret is kept until the next refresh
+	clock.Advance(CookieRefreshTime / 2)
+	reply, err := checker.CreateReply(data, 0, src)
+	assert.NoError(t, err)
+	assert.NoError(t, generator.ConsumeReply(reply))
+	generator.AddMACs(data)
+	assert.Equal(t, fresh[len(fresh)-MACSize:], data[len(data)-MACSize:])
+}
+
+func TestCookieReplyInvalid(t *testing.T) {
+	t.Parallel()
+
+	rPub, otherPub := cookieTestKeys(t)
+
+	checker := NewCookieChecker(&rPub)
+	otherChecker := NewCookieChecker(&otherPub)
+	generator := NewCookieGenerator(&rPub)
+
+	src := []byte("127.0.0.1:4242")
+
+	var msg MessageInitiation
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = checker.CreateReply(nil, 0, src)
+	assert.Equal(t, ErrInvalidMessageSize, err)
+
+	// reply without a message sent
+	reply, err := checker.CreateReply(data, 0, src)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidCookieReply, generator.ConsumeReply(reply))
+
+	generator.AddMACs(data)
+
+	// reply from another recipient
+	reply, err = otherChecker.CreateReply(data, 0, src)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidCookieReply, generator.ConsumeReply(reply))
+
+	// tampered reply
+	reply, err = checker.CreateReply(data, 0, src)
+	assert.NoError(t, err)
+	reply.Cookie[0] ^= 0xFF
+	assert.Equal(t, ErrInvalidCookieReply, generator.ConsumeReply(reply))
+}
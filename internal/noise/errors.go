@@ -8,4 +8,39 @@ var (
 
 	// ErrBadHandshakeRole ...
 	ErrBadHandshakeRole = errors.New("bad handshake role")
+
+	// ErrSessionExpired is returned when a session reached its message limit.
+	ErrSessionExpired = errors.New("session expired")
+
+	// ErrReplayedPacket is returned when a packet counter was already seen.
+	ErrReplayedPacket = errors.New("replayed packet")
+
+	// ErrNoSession is returned when a keyring has no usable session.
+	ErrNoSession = errors.New("no session")
+
+	// ErrShortPacket is returned when a packet is too short to be opened.
+	ErrShortPacket = errors.New("short packet")
+
+	// ErrInvalidMessageSize is returned when decoding a message of the wrong
+	// size.
+	ErrInvalidMessageSize = errors.New("invalid message size")
+
+	// ErrInvalidMessageType is returned when decoding a message with an
+	// unexpected type or malformed header.
+	ErrInvalidMessageType = errors.New("invalid message type")
+
+	// ErrInvalidCookieReply is returned when a cookie reply can't be
+	// decrypted or does not answer the last message sent.
+	ErrInvalidCookieReply = errors.New("invalid cookie reply")
+
+	// ErrReplayedTimestamp is returned when a handshake initiation carries a
+	// timestamp older than or equal to the greatest one seen for its sender.
+	ErrReplayedTimestamp = errors.New("replayed timestamp")
+
+	// ErrUnknownPeer is returned when a handshake sender is not present or
+	// not allowed in the recipient peer table.
+	ErrUnknownPeer = errors.New("unknown peer")
+
+	// ErrPeerExists is returned when adding a peer already in a peer table.
+	ErrPeerExists = errors.New("peer already exists")
 )
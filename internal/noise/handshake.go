@@ -5,6 +5,14 @@ import (
 	"cpl.li/go/cryptor/internal/crypt/ppk"
 )
 
+// Construction identifies the handshake protocol and its primitives. It is the
+// first value mixed into every handshake transcript, domain separating it from
+// any other use of the same keys or hash function. Handshakes using a hash
+// algorithm other than BLAKE2s replace its last field with the algorithm name.
+const Construction = "Cryptor v1 X25519 ChaChaPoly BLAKE2s"
+
+const constructionPrefix = "Cryptor v1 X25519 ChaChaPoly "
+
 type handshakeRole byte
 
 const (
@@ -19,6 +27,7 @@ const (
 	handshakeStateEmpty handshakeState = iota
 	handshakeStateInitialized
 	handshakeStateExchanged
+	handshakeStateTimestamped
 	handshakeStateFinal
 	handshakeStateComplete
 )
@@ -28,10 +37,15 @@ type Handshake struct {
 	state handshakeState
 	role  handshakeRole
 
-	hash    hashing.HashSum
-	c, t, k [hashing.HashSize]byte
+	algorithm hashing.Algorithm
+	hash      hashing.HashSum
+	c, t, k   [hashing.HashSize]byte
 
 	presharedKey [ppk.KeySize]byte
+	prologue     []byte
+	timestamps   *TimestampFilter
+	peers        *PeerTable
+	peer         *Peer
 
 	tempKeys struct {
 		secret ppk.PrivateKey
@@ -44,6 +58,95 @@ func (hs *Handshake) PublicKey() ppk.PublicKey {
 	return hs.tempKeys.public
 }
 
+// SetPresharedKey sets the optional symmetric key mixed into the handshake
+// before the transport keys are derived. Both parties must use the same
+// preshared key or the handshake fails. It can only be set before the
+// handshake is initialized.
+func (hs *Handshake) SetPresharedKey(psk *[ppk.KeySize]byte) error {
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
+
+	hs.presharedKey = *psk
+
+	return nil
+}
+
+// SetPrologue sets optional application data mixed into the handshake
+// transcript before any keys. Both parties must use the same prologue or the
+// handshake fails. It can only be set before the handshake is initialized.
+func (hs *Handshake) SetPrologue(prologue []byte) error {
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
+
+	hs.prologue = append([]byte(nil), prologue...)
+
+	return nil
+}
+
+// SetTimestampFilter sets the filter a recipient checks decrypted initiation
+// timestamps against, so replayed initiations are rejected. It can only be set
+// before the handshake is initialized.
+func (hs *Handshake) SetTimestampFilter(tf *TimestampFilter) error {
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
+
+	hs.timestamps = tf
+
+	return nil
+}
+
+// SetPeerTable sets the table a recipient looks up the decrypted sender static
+// key in. Senders not present or not allowed in the table are rejected, and
+// the preshared key of the matching peer is used for the handshake. It can
+// only be set before the handshake is initialized.
+func (hs *Handshake) SetPeerTable(pt *PeerTable) error {
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
+
+	hs.peers = pt
+
+	return nil
+}
+
+// Peer returns the peer matched in the recipient peer table, or nil.
+func (hs *Handshake) Peer() *Peer {
+	return hs.peer
+}
+
+// SetHashAlgorithm sets the hash algorithm used for the transcript and key
+// derivation, BLAKE2s by default. Both parties must use the same algorithm or
+// the handshake fails. It can only be set before the handshake is initialized.
+func (hs *Handshake) SetHashAlgorithm(alg hashing.Algorithm) error {
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
+	if !alg.Available() || alg.Size() != hashing.HashSize {
+		return hashing.ErrUnknownAlgorithm
+	}
+
+	hs.algorithm = alg
+
+	return nil
+}
+
+// HashAlgorithm returns the hash algorithm used by the handshake.
+func (hs *Handshake) HashAlgorithm() hashing.Algorithm {
+	return hs.algorithm
+}
+
+// mixPrologue starts the transcript from the construction, the prologue and
+// the recipient static public key.
+func (hs *Handshake) mixPrologue(rPub *ppk.PublicKey) {
+	hashing.HashWith(hs.algorithm, &hs.hash,
+		[]byte(constructionPrefix+hs.algorithm.String()))
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], hs.prologue)
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], rPub[:])
+}
+
 func (hs *Handshake) keygen() (err error) {
 	if err := ppk.NewPrivateKey(&hs.tempKeys.secret); err != nil {
 		return err
@@ -19,13 +19,14 @@ func (hs *Handshake) InitializeSender(rPub *ppk.PublicKey) error {
 		return err
 	}
 
-	hashing.Hash(&hs.hash, rPub[:])
-	hkdf.HKDF(hs.hash[:], hs.tempKeys.public[:], &hs.c)
-
 	var ss [ppk.KeySize]byte
+	if err := hs.tempKeys.secret.SharedSecret(rPub, &ss); err != nil {
+		return err
+	}
 
-	hs.tempKeys.secret.SharedSecret(rPub, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c, &hs.k)
+	hs.mixPrologue(rPub)
+	hkdf.HKDFWith(hs.algorithm, hs.hash[:], hs.tempKeys.public[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ss[:], &hs.c, &hs.k)
 
 	hs.role = handshakeRoleSender
 	hs.state = handshakeStateInitialized
@@ -35,16 +36,21 @@ func (hs *Handshake) InitializeSender(rPub *ppk.PublicKey) error {
 
 // InitializeRecipient ...
 func (hs *Handshake) InitializeRecipient(rSec *ppk.PrivateKey, cPubTmp *ppk.PublicKey) error {
-	var rPub ppk.PublicKey
-	rSec.PublicKey(&rPub)
-
-	hashing.Hash(&hs.hash, rPub[:])
-	hkdf.HKDF(hs.hash[:], cPubTmp[:], &hs.c)
+	if hs.state != handshakeStateEmpty {
+		return ErrBadHandshakeState
+	}
 
 	var ss [ppk.KeySize]byte
+	if err := rSec.SharedSecret(cPubTmp, &ss); err != nil {
+		return err
+	}
+
+	var rPub ppk.PublicKey
+	rSec.PublicKey(&rPub)
 
-	rSec.SharedSecret(cPubTmp, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c, &hs.k)
+	hs.mixPrologue(&rPub)
+	hkdf.HKDFWith(hs.algorithm, hs.hash[:], cPubTmp[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ss[:], &hs.c, &hs.k)
 
 	if err := hs.keygen(); err != nil {
 		return err
@@ -72,42 +78,101 @@ func (hs *Handshake) Exchange(cPub *ppk.PublicKey, cPubEnc *EncryptedKey) error
 		if err != nil {
 			return err
 		}
+		if hs.peers != nil {
+			peer := hs.peers.Lookup(cPub)
+			if peer == nil || !peer.Allowed() {
+				return ErrUnknownPeer
+			}
+			hs.peer = peer
+			hs.presharedKey = peer.PresharedKey()
+		}
 	default:
 		return ErrBadHandshakeRole
 	}
 
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], cPubEnc[:])
+
 	hs.state = handshakeStateExchanged
 
 	return nil
 }
 
+// ExchangeTimestamp ...
+func (hs *Handshake) ExchangeTimestamp(sec *ppk.PrivateKey, pub *ppk.PublicKey, ts *Timestamp, tsEnc *EncryptedTimestamp) error {
+	if hs.state != handshakeStateExchanged {
+		return ErrBadHandshakeState
+	}
+
+	var ss [ppk.KeySize]byte
+	if err := sec.SharedSecret(pub, &ss); err != nil {
+		return err
+	}
+
+	var (
+		snapshotC = hs.c
+		snapshotK = hs.k
+	)
+
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ss[:], &hs.c, &hs.k)
+
+	switch hs.role {
+	case handshakeRoleSender:
+		cipher, _ := chacha.New(hs.k[:])
+		cipher.Seal(tsEnc[:0], zeroNonce[:], ts[:], hs.hash[:])
+	case handshakeRoleRecipient:
+		cipher, _ := chacha.New(hs.k[:])
+		_, err := cipher.Open(ts[:0], zeroNonce[:], tsEnc[:], hs.hash[:])
+		if err == nil && hs.timestamps != nil {
+			err = hs.timestamps.Check(pub, ts)
+		}
+		if err != nil {
+			hs.c = snapshotC
+			hs.k = snapshotK
+
+			return err
+		}
+	default:
+		return ErrBadHandshakeRole
+	}
+
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], tsEnc[:])
+
+	hs.state = handshakeStateTimestamped
+
+	return nil
+}
+
 // PrepareRecipientResponse ...
 func (hs *Handshake) PrepareRecipientResponse(sPubTmp, sPub *ppk.PublicKey, enc *EncryptedNothing) error {
 	if hs.role != handshakeRoleRecipient {
 		return ErrBadHandshakeRole
 	}
 
-	if hs.state != handshakeStateExchanged {
+	if hs.state != handshakeStateTimestamped {
 		return ErrBadHandshakeState
 	}
 
-	hkdf.HKDF(hs.c[:], hs.tempKeys.public[:], &hs.c)
-	hashing.Hash(&hs.hash, hs.hash[:], hs.tempKeys.public[:])
+	var ssTmp, ss [ppk.KeySize]byte
+	if err := hs.tempKeys.secret.SharedSecret(sPubTmp, &ssTmp); err != nil {
+		return err
+	}
+	if err := hs.tempKeys.secret.SharedSecret(sPub, &ss); err != nil {
+		return err
+	}
 
-	var ss [ppk.KeySize]byte
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], hs.tempKeys.public[:], &hs.c)
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], hs.tempKeys.public[:])
 
-	hs.tempKeys.secret.SharedSecret(sPubTmp, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c)
-	hs.tempKeys.secret.SharedSecret(sPub, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ssTmp[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ss[:], &hs.c)
 
-	hkdf.HKDF(hs.c[:], hs.presharedKey[:], &hs.c, &hs.t, &hs.k)
-	hashing.Hash(&hs.hash, hs.hash[:], hs.t[:])
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], hs.presharedKey[:], &hs.c, &hs.t, &hs.k)
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], hs.t[:])
 
 	cipher, _ := chacha.New(hs.k[:])
 	cipher.Seal(enc[:0], zeroNonce[:], nil, hs.hash[:])
 
-	hashing.Hash(&hs.hash, enc[:])
+	hashing.HashWith(hs.algorithm, &hs.hash, enc[:])
 
 	hs.state = handshakeStateFinal
 
@@ -120,10 +185,18 @@ func (hs *Handshake) ConsumeRecipientResponse(sSec *ppk.PrivateKey, rPubTmp *ppk
 		return ErrBadHandshakeRole
 	}
 
-	if hs.state != handshakeStateExchanged {
+	if hs.state != handshakeStateTimestamped {
 		return ErrBadHandshakeState
 	}
 
+	var ssTmp, ss [ppk.KeySize]byte
+	if err := hs.tempKeys.secret.SharedSecret(rPubTmp, &ssTmp); err != nil {
+		return err
+	}
+	if err := sSec.SharedSecret(rPubTmp, &ss); err != nil {
+		return err
+	}
+
 	var (
 		snapshotHash = hs.hash
 		snapshotC    = hs.c
@@ -131,18 +204,14 @@ func (hs *Handshake) ConsumeRecipientResponse(sSec *ppk.PrivateKey, rPubTmp *ppk
 		snapshotT    = hs.t
 	)
 
-	hkdf.HKDF(hs.c[:], rPubTmp[:], &hs.c)
-	hashing.Hash(&hs.hash, hs.hash[:], rPubTmp[:])
-
-	var ss [ppk.KeySize]byte
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], rPubTmp[:], &hs.c)
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], rPubTmp[:])
 
-	hs.tempKeys.secret.SharedSecret(rPubTmp, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c)
-	sSec.SharedSecret(rPubTmp, &ss)
-	hkdf.HKDF(hs.c[:], ss[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ssTmp[:], &hs.c)
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], ss[:], &hs.c)
 
-	hkdf.HKDF(hs.c[:], hs.presharedKey[:], &hs.c, &hs.t, &hs.k)
-	hashing.Hash(&hs.hash, hs.hash[:], hs.t[:])
+	hkdf.HKDFWith(hs.algorithm, hs.c[:], hs.presharedKey[:], &hs.c, &hs.t, &hs.k)
+	hashing.HashWith(hs.algorithm, &hs.hash, hs.hash[:], hs.t[:])
 
 	cipher, _ := chacha.New(hs.k[:])
 	_, err := cipher.Open(nil, zeroNonce[:], enc[:], hs.hash[:])
@@ -155,7 +224,7 @@ func (hs *Handshake) ConsumeRecipientResponse(sSec *ppk.PrivateKey, rPubTmp *ppk
 		return err
 	}
 
-	hashing.Hash(&hs.hash, enc[:])
+	hashing.HashWith(hs.algorithm, &hs.hash, enc[:])
 
 	hs.state = handshakeStateFinal
 
@@ -170,16 +239,16 @@ func (hs *Handshake) Finalize(send, recv *[ppk.KeySize]byte) error {
 
 	switch hs.role {
 	case handshakeRoleSender:
-		hkdf.HKDF(hs.c[:], nil, send, recv)
+		hkdf.HKDFWith(hs.algorithm, hs.c[:], nil, send, recv)
 	case handshakeRoleRecipient:
-		hkdf.HKDF(hs.c[:], nil, recv, send)
+		hkdf.HKDFWith(hs.algorithm, hs.c[:], nil, recv, send)
 	default:
 		return ErrBadHandshakeRole
 	}
 
 	hs.state = handshakeStateComplete
 
-	crypt.ZeroBytes(hs.k[:], hs.c[:], hs.t[:],
+	crypt.ZeroBytes(hs.k[:], hs.c[:], hs.t[:], hs.presharedKey[:],
 		hs.tempKeys.public[:], hs.tempKeys.secret[:])
 
 	return nil
@@ -7,12 +7,17 @@ import (
 var zeroNonce [chacha.NonceSize]byte
 
 const (
-	encryptedKeySize     = 48
-	encryptedNothingSize = 16
+	encryptedKeySize       = 48
+	encryptedNothingSize   = 16
+	encryptedTimestampSize = TimestampSize + 16
+	encryptedCookieSize    = cookieSize + 16
 )
 
 // EncryptedKey ...
 type EncryptedKey [encryptedKeySize]byte
 
+// EncryptedTimestamp ...
+type EncryptedTimestamp [encryptedTimestampSize]byte
+
 // EncryptedNothing ...
 type EncryptedNothing [encryptedNothingSize]byte
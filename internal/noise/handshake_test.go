@@ -2,7 +2,10 @@ package noise
 
 import (
 	"testing"
+	"time"
 
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/hashing"
 	"cpl.li/go/cryptor/internal/crypt/ppk"
 
 	"github.com/stretchr/testify/assert"
@@ -20,6 +23,9 @@ func TestHandshakeFlow(t *testing.T) {
 		rPub       ppk.PublicKey
 		sPubOut    ppk.PublicKey
 		sPubEnc    EncryptedKey
+		ts         Timestamp
+		tsOut      Timestamp
+		tsEnc      EncryptedTimestamp
 		enc        EncryptedNothing
 		sSend      [ppk.KeySize]byte
 		sRecv      [ppk.KeySize]byte
@@ -37,11 +43,15 @@ func TestHandshakeFlow(t *testing.T) {
 	assert.NoError(t, sHandshake.InitializeSender(&rPub))
 	assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
 
+	// encrypt initiation timestamp
+	ts = NewTimestamp(time.Now())
+	assert.NoError(t, sHandshake.ExchangeTimestamp(&sSec, &rPub, &ts, &tsEnc))
+
 	// generate sender temp pub
 	sPubTmp := sHandshake.PublicKey()
 
-	// abstract away how sender public temp key and sender pub encrypted
-	// are sent to recipient
+	// abstract away how sender public temp key, sender pub encrypted and
+	// the encrypted timestamp are sent to recipient
 
 	// receive sender keys and init recipient
 	assert.NoError(t, rHandshake.InitializeRecipient(&rSec, &sPubTmp))
@@ -50,8 +60,12 @@ func TestHandshakeFlow(t *testing.T) {
 	// check pub matches after encryption and decryption
 	assert.Equal(t, sPub, sPubOut)
 
+	// decrypt initiation timestamp
+	assert.NoError(t, rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &tsOut, &tsEnc))
+	assert.Equal(t, ts, tsOut)
+
 	// recipient prepares response
-	rHandshake.PrepareRecipientResponse(&sPubTmp, &sPub, &enc)
+	assert.NoError(t, rHandshake.PrepareRecipientResponse(&sPubTmp, &sPub, &enc))
 	rPubTmp := rHandshake.PublicKey()
 
 	// abstract away how the recipient sends the pub temp and the enc nothing
@@ -66,3 +80,265 @@ func TestHandshakeFlow(t *testing.T) {
 	assert.Equal(t, rSend, sRecv)
 	assert.Equal(t, rRecv, sSend)
 }
+
+func TestHandshakeLowOrderPoint(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sHandshake Handshake
+		rHandshake Handshake
+		sSec       ppk.PrivateKey
+		sPub       ppk.PublicKey
+		rSec       ppk.PrivateKey
+		rPub       ppk.PublicKey
+		zero       ppk.PublicKey
+		sPubOut    ppk.PublicKey
+		sPubEnc    EncryptedKey
+		ts         = NewTimestamp(time.Now())
+		tsEnc      EncryptedTimestamp
+		enc        EncryptedNothing
+		sSend      [ppk.KeySize]byte
+		sRecv      [ppk.KeySize]byte
+		rSend      [ppk.KeySize]byte
+		rRecv      [ppk.KeySize]byte
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+
+	// every step rejects a low order point and can be retried afterwards
+	assert.Equal(t, ppk.ErrLowOrderPoint, sHandshake.InitializeSender(&zero))
+	assert.NoError(t, sHandshake.InitializeSender(&rPub))
+	assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
+	assert.Equal(t, ppk.ErrLowOrderPoint,
+		sHandshake.ExchangeTimestamp(&sSec, &zero, &ts, &tsEnc))
+	assert.NoError(t, sHandshake.ExchangeTimestamp(&sSec, &rPub, &ts, &tsEnc))
+	sPubTmp := sHandshake.PublicKey()
+
+	assert.Equal(t, ppk.ErrLowOrderPoint, rHandshake.InitializeRecipient(&rSec, &zero))
+	assert.NoError(t, rHandshake.InitializeRecipient(&rSec, &sPubTmp))
+	assert.NoError(t, rHandshake.Exchange(&sPubOut, &sPubEnc))
+	assert.NoError(t, rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &ts, &tsEnc))
+	assert.Equal(t, ppk.ErrLowOrderPoint,
+		rHandshake.PrepareRecipientResponse(&zero, &sPubOut, &enc))
+	assert.Equal(t, ppk.ErrLowOrderPoint,
+		rHandshake.PrepareRecipientResponse(&sPubTmp, &zero, &enc))
+	assert.NoError(t, rHandshake.PrepareRecipientResponse(&sPubTmp, &sPubOut, &enc))
+	rPubTmp := rHandshake.PublicKey()
+
+	assert.Equal(t, ppk.ErrLowOrderPoint,
+		sHandshake.ConsumeRecipientResponse(&sSec, &zero, &enc))
+	assert.NoError(t, sHandshake.ConsumeRecipientResponse(&sSec, &rPubTmp, &enc))
+
+	assert.NoError(t, rHandshake.Finalize(&rSend, &rRecv))
+	assert.NoError(t, sHandshake.Finalize(&sSend, &sRecv))
+
+	assert.Equal(t, rSend, sRecv)
+	assert.Equal(t, rRecv, sSend)
+}
+
+// handshakePair runs the handshake flow up to the final state and returns the
+// sender and recipient handshakes, ready to be finalized.
+func handshakePair(t *testing.T) (sHandshake, rHandshake *Handshake) {
+	sHandshake = new(Handshake)
+	rHandshake = new(Handshake)
+
+	assert.NoError(t, runHandshake(t, sHandshake, rHandshake))
+
+	return sHandshake, rHandshake
+}
+
+// runHandshake runs the handshake flow between the given handshakes using
+// fresh static keys, returning the first error encountered by the recipient
+// or by the sender consuming the recipient response.
+func runHandshake(t *testing.T, sHandshake, rHandshake *Handshake) error {
+	var (
+		sSec    ppk.PrivateKey
+		sPub    ppk.PublicKey
+		rSec    ppk.PrivateKey
+		rPub    ppk.PublicKey
+		sPubOut ppk.PublicKey
+		sPubEnc EncryptedKey
+		ts      = NewTimestamp(time.Now())
+		tsEnc   EncryptedTimestamp
+		enc     EncryptedNothing
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+
+	assert.NoError(t, sHandshake.InitializeSender(&rPub))
+	assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
+	assert.NoError(t, sHandshake.ExchangeTimestamp(&sSec, &rPub, &ts, &tsEnc))
+	sPubTmp := sHandshake.PublicKey()
+
+	if err := rHandshake.InitializeRecipient(&rSec, &sPubTmp); err != nil {
+		return err
+	}
+	if err := rHandshake.Exchange(&sPubOut, &sPubEnc); err != nil {
+		return err
+	}
+	if err := rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &ts, &tsEnc); err != nil {
+		return err
+	}
+	if err := rHandshake.PrepareRecipientResponse(&sPubTmp, &sPubOut, &enc); err != nil {
+		return err
+	}
+	rPubTmp := rHandshake.PublicKey()
+
+	return sHandshake.ConsumeRecipientResponse(&sSec, &rPubTmp, &enc)
+}
+
+func TestHandshakePresharedKey(t *testing.T) {
+	t.Parallel()
+
+	var psk [ppk.KeySize]byte
+	copy(psk[:], crypt.RandomBytes(ppk.KeySize))
+
+	// matching keys
+	var sHandshake, rHandshake Handshake
+	assert.NoError(t, sHandshake.SetPresharedKey(&psk))
+	assert.NoError(t, rHandshake.SetPresharedKey(&psk))
+	assert.NoError(t, runHandshake(t, &sHandshake, &rHandshake))
+
+	sSession, err := NewSession(&sHandshake)
+	assert.NoError(t, err)
+	rSession, err := NewSession(&rHandshake)
+	assert.NoError(t, err)
+
+	packet, err := sSession.Seal([]byte("We attack at dawn"))
+	assert.NoError(t, err)
+	_, err = rSession.Open(packet)
+	assert.NoError(t, err)
+
+	// mismatched keys
+	var otherPSK [ppk.KeySize]byte
+	copy(otherPSK[:], crypt.RandomBytes(ppk.KeySize))
+
+	sHandshake, rHandshake = Handshake{}, Handshake{}
+	assert.NoError(t, sHandshake.SetPresharedKey(&psk))
+	assert.NoError(t, rHandshake.SetPresharedKey(&otherPSK))
+	assert.Error(t, runHandshake(t, &sHandshake, &rHandshake))
+
+	// only one side has a key
+	sHandshake, rHandshake = Handshake{}, Handshake{}
+	assert.NoError(t, rHandshake.SetPresharedKey(&psk))
+	assert.Error(t, runHandshake(t, &sHandshake, &rHandshake))
+}
+
+func TestHandshakePresharedKeyState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		hs  Handshake
+		psk [ppk.KeySize]byte
+		sec ppk.PrivateKey
+		pub ppk.PublicKey
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+	assert.NoError(t, sec.PublicKey(&pub))
+	assert.NoError(t, hs.InitializeSender(&pub))
+	assert.Equal(t, ErrBadHandshakeState, hs.SetPresharedKey(&psk))
+}
+
+func TestHandshakePrologue(t *testing.T) {
+	t.Parallel()
+
+	prologue := []byte("cryptor test application")
+
+	// matching prologues
+	var sHandshake, rHandshake Handshake
+	assert.NoError(t, sHandshake.SetPrologue(prologue))
+	assert.NoError(t, rHandshake.SetPrologue(prologue))
+	assert.NoError(t, runHandshake(t, &sHandshake, &rHandshake))
+
+	// mismatched prologues
+	sHandshake, rHandshake = Handshake{}, Handshake{}
+	assert.NoError(t, sHandshake.SetPrologue(prologue))
+	assert.NoError(t, rHandshake.SetPrologue([]byte("cryptor other application")))
+	assert.Error(t, runHandshake(t, &sHandshake, &rHandshake))
+
+	// only one side has a prologue
+	sHandshake, rHandshake = Handshake{}, Handshake{}
+	assert.NoError(t, sHandshake.SetPrologue(prologue))
+	assert.Error(t, runHandshake(t, &sHandshake, &rHandshake))
+
+	// the prologue is copied
+	sHandshake, rHandshake = Handshake{}, Handshake{}
+	assert.NoError(t, sHandshake.SetPrologue(prologue))
+	assert.NoError(t, rHandshake.SetPrologue(prologue))
+	prologue[0] ^= 0xFF
+	assert.NoError(t, runHandshake(t, &sHandshake, &rHandshake))
+}
+
+func TestHandshakePrologueState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		hs  Handshake
+		sec ppk.PrivateKey
+		pub ppk.PublicKey
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+	assert.NoError(t, sec.PublicKey(&pub))
+	assert.NoError(t, hs.InitializeSender(&pub))
+	assert.Equal(t, ErrBadHandshakeState, hs.SetPrologue([]byte("late")))
+}
+
+func TestHandshakeHashAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []hashing.Algorithm{
+		hashing.BLAKE2s,
+		hashing.BLAKE2b,
+		hashing.SHA256,
+		hashing.SHA3_256,
+	} {
+		var sHandshake, rHandshake Handshake
+		assert.NoError(t, sHandshake.SetHashAlgorithm(alg))
+		assert.NoError(t, rHandshake.SetHashAlgorithm(alg))
+		assert.Equal(t, alg, sHandshake.HashAlgorithm())
+		assert.NoError(t, runHandshake(t, &sHandshake, &rHandshake), alg)
+
+		sSession, err := NewSession(&sHandshake)
+		assert.NoError(t, err)
+		rSession, err := NewSession(&rHandshake)
+		assert.NoError(t, err)
+
+		packet, err := sSession.Seal([]byte("hello"))
+		assert.NoError(t, err)
+		plaintext, err := rSession.Open(packet)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), plaintext)
+	}
+
+	// mismatched algorithms
+	var sHandshake, rHandshake Handshake
+	assert.NoError(t, sHandshake.SetHashAlgorithm(hashing.SHA256))
+	assert.Error(t, runHandshake(t, &sHandshake, &rHandshake))
+}
+
+func TestHandshakeHashAlgorithmState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		hs  Handshake
+		sec ppk.PrivateKey
+		pub ppk.PublicKey
+	)
+
+	assert.Equal(t, hashing.ErrUnknownAlgorithm,
+		hs.SetHashAlgorithm(hashing.Algorithm(0xFF)))
+	assert.Equal(t, hashing.BLAKE2s, hs.HashAlgorithm())
+
+	assert.NoError(t, ppk.NewPrivateKey(&sec))
+	assert.NoError(t, sec.PublicKey(&pub))
+	assert.NoError(t, hs.InitializeSender(&pub))
+	assert.Equal(t, ErrBadHandshakeState, hs.SetHashAlgorithm(hashing.SHA256))
+}
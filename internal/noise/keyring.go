@@ -0,0 +1,148 @@
+package noise
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyring holds the current session with a peer and the session it replaced.
+// The previous session is kept for KeepPreviousTime after a rotation so
+// packets in flight during a rekey can still be opened. Sessions are dropped
+// once expired. A Keyring is safe for concurrent use.
+type Keyring struct {
+	lock sync.RWMutex
+
+	policy SessionPolicy
+	clock  Clock
+
+	current  *Session
+	previous *Session
+	rotated  time.Time
+}
+
+// NewKeyring returns an empty keyring, expiring previous sessions according
+// to the given policy as measured by the clock.
+func NewKeyring(policy *SessionPolicy, clock Clock) *Keyring {
+	return &Keyring{
+		policy: *policy,
+		clock:  clock,
+	}
+}
+
+// SetPolicy changes the policy deciding how long the previous session is
+// kept.
+func (kr *Keyring) SetPolicy(policy *SessionPolicy) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	kr.policy = *policy
+}
+
+func (kr *Keyring) configure(policy *SessionPolicy, clock Clock) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	kr.policy = *policy
+	kr.clock = clock
+}
+
+// Rotate makes the session the current one, keeping the replaced session as
+// the previous one.
+func (kr *Keyring) Rotate(session *Session) {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	kr.previous = kr.current
+	kr.current = session
+	kr.rotated = kr.clock.Now()
+}
+
+// Clear drops all sessions.
+func (kr *Keyring) Clear() {
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+
+	kr.previous = nil
+	kr.current = nil
+}
+
+// Current returns the current session, or nil if there is none or it expired.
+func (kr *Keyring) Current() *Session {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+
+	return kr.liveCurrent()
+}
+
+// Previous returns the previous session, or nil if there is none, it expired
+// or it was replaced more than KeepPreviousTime ago.
+func (kr *Keyring) Previous() *Session {
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+
+	return kr.livePrevious()
+}
+
+// NeedsRekey returns true if there is no usable current session or it should
+// be replaced by a new handshake.
+func (kr *Keyring) NeedsRekey() bool {
+	current := kr.Current()
+
+	return current == nil || current.NeedsRekey()
+}
+
+// Seal seals the plaintext using the current session.
+func (kr *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	current := kr.Current()
+	if current == nil {
+		return nil, ErrNoSession
+	}
+
+	return current.Seal(plaintext)
+}
+
+// Open opens the packet using the current session, falling back to the
+// previous session.
+func (kr *Keyring) Open(packet []byte) ([]byte, error) {
+	kr.lock.RLock()
+	current, previous := kr.liveCurrent(), kr.livePrevious()
+	kr.lock.RUnlock()
+
+	if current == nil && previous == nil {
+		return nil, ErrNoSession
+	}
+
+	var err error
+
+	for _, session := range []*Session{current, previous} {
+		if session == nil {
+			continue
+		}
+
+		var plaintext []byte
+		if plaintext, err = session.Open(packet); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (kr *Keyring) liveCurrent() *Session {
+	if kr.current == nil || kr.current.Expired() {
+		return nil
+	}
+
+	return kr.current
+}
+
+func (kr *Keyring) livePrevious() *Session {
+	if kr.previous == nil || kr.previous.Expired() {
+		return nil
+	}
+	if kr.clock.Now().Sub(kr.rotated) >= kr.policy.KeepPreviousTime {
+		return nil
+	}
+
+	return kr.previous
+}
@@ -0,0 +1,187 @@
+package noise
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1586000000, 0)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	fc.now = fc.now.Add(d)
+}
+
+func sessionPairWithPolicy(t *testing.T, policy *SessionPolicy, clock Clock) (sSession, rSession *Session) {
+	sHandshake, rHandshake := handshakePair(t)
+
+	sSession, err := NewSessionWithPolicy(sHandshake, policy, clock)
+	assert.NoError(t, err)
+	rSession, err = NewSessionWithPolicy(rHandshake, policy, clock)
+	assert.NoError(t, err)
+
+	return sSession, rSession
+}
+
+func TestSessionLifetime(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	sSession, rSession := sessionPairWithPolicy(t, &DefaultSessionPolicy, clock)
+	assert.Equal(t, clock.Now(), sSession.Created())
+
+	packet, err := sSession.Seal(nil)
+	assert.NoError(t, err)
+	assert.False(t, sSession.NeedsRekey())
+	assert.False(t, sSession.Expired())
+
+	clock.Advance(RekeyAfterTime)
+	assert.True(t, sSession.NeedsRekey())
+	assert.False(t, sSession.Expired())
+
+	// still usable until rejected
+	_, err = rSession.Open(packet)
+	assert.NoError(t, err)
+	packet, err = sSession.Seal(nil)
+	assert.NoError(t, err)
+
+	clock.Advance(RejectAfterTime - RekeyAfterTime)
+	assert.True(t, sSession.Expired())
+
+	_, err = sSession.Seal(nil)
+	assert.Equal(t, ErrSessionExpired, err)
+	_, err = rSession.Open(packet)
+	assert.Equal(t, ErrSessionExpired, err)
+}
+
+func TestSessionMessagePolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := DefaultSessionPolicy
+	policy.RekeyAfterMessages = 2
+	policy.RejectAfterMessages = 3
+
+	sSession, _ := sessionPairWithPolicy(t, &policy, newFakeClock())
+
+	for iter := 0; iter < 2; iter++ {
+		assert.False(t, sSession.NeedsRekey())
+		_, err := sSession.Seal(nil)
+		assert.NoError(t, err)
+	}
+
+	assert.True(t, sSession.NeedsRekey())
+	assert.False(t, sSession.Expired())
+
+	_, err := sSession.Seal(nil)
+	assert.NoError(t, err)
+	assert.True(t, sSession.Expired())
+
+	_, err = sSession.Seal(nil)
+	assert.Equal(t, ErrSessionExpired, err)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+
+	sKeyring := NewKeyring(&DefaultSessionPolicy, clock)
+	rKeyring := NewKeyring(&DefaultSessionPolicy, clock)
+
+	assert.True(t, sKeyring.NeedsRekey())
+	_, err := sKeyring.Seal(nil)
+	assert.Equal(t, ErrNoSession, err)
+	_, err = rKeyring.Open(make([]byte, SessionOverhead))
+	assert.Equal(t, ErrNoSession, err)
+
+	sOld, rOld := sessionPairWithPolicy(t, &DefaultSessionPolicy, clock)
+	sKeyring.Rotate(sOld)
+	rKeyring.Rotate(rOld)
+	assert.Equal(t, sOld, sKeyring.Current())
+	assert.Nil(t, sKeyring.Previous())
+	assert.False(t, sKeyring.NeedsRekey())
+
+	// packet sealed before the rekey, still in flight
+	inFlight, err := sKeyring.Seal([]byte("old"))
+	assert.NoError(t, err)
+
+	clock.Advance(RekeyAfterTime)
+	assert.True(t, sKeyring.NeedsRekey())
+
+	sNew, rNew := sessionPairWithPolicy(t, &DefaultSessionPolicy, clock)
+	sKeyring.Rotate(sNew)
+	rKeyring.Rotate(rNew)
+	assert.Equal(t, sNew, sKeyring.Current())
+	assert.Equal(t, sOld, sKeyring.Previous())
+	assert.False(t, sKeyring.NeedsRekey())
+
+	packet, err := sKeyring.Seal([]byte("new"))
+	assert.NoError(t, err)
+
+	msg, err := rKeyring.Open(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("new"), msg)
+
+	msg, err = rKeyring.Open(inFlight)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("old"), msg)
+
+	// previous session is only kept briefly
+	inFlight, err = sOld.Seal([]byte("old"))
+	assert.NoError(t, err)
+
+	clock.Advance(KeepPreviousTime)
+	assert.Nil(t, rKeyring.Previous())
+	_, err = rKeyring.Open(inFlight)
+	assert.Error(t, err)
+
+	// current session expires without a rekey
+	clock.Advance(RejectAfterTime)
+	assert.Nil(t, sKeyring.Current())
+	assert.True(t, sKeyring.NeedsRekey())
+	_, err = sKeyring.Seal(nil)
+	assert.Equal(t, ErrNoSession, err)
+
+	sKeyring.Clear()
+	assert.Nil(t, sKeyring.Current())
+}
+
+func TestKeyringSetPolicy(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	keyring := NewKeyring(&DefaultSessionPolicy, clock)
+
+	sOld, _ := sessionPairWithPolicy(t, &DefaultSessionPolicy, clock)
+	sNew, _ := sessionPairWithPolicy(t, &DefaultSessionPolicy, clock)
+	keyring.Rotate(sOld)
+	keyring.Rotate(sNew)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, sOld, keyring.Previous())
+
+	policy := DefaultSessionPolicy
+	policy.KeepPreviousTime = time.Second
+	keyring.SetPolicy(&policy)
+	assert.Nil(t, keyring.Previous())
+	assert.Equal(t, sNew, keyring.Current())
+}
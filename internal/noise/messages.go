@@ -0,0 +1,238 @@
+package noise
+
+import (
+	"encoding/binary"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	chacha "golang.org/x/crypto/chacha20poly1305"
+)
+
+// Message types, always the first byte of a message on the wire.
+const (
+	MessageTypeInitiation byte = iota + 1
+	MessageTypeResponse
+	MessageTypeCookieReply
+	MessageTypeTransport
+)
+
+const (
+	// MACSize is the size of the mac1 and mac2 fields of handshake messages.
+	MACSize = 16
+
+	// MessageInitiationSize is the size of an encoded MessageInitiation.
+	MessageInitiationSize = 4 + 4 + ppk.KeySize + encryptedKeySize +
+		encryptedTimestampSize + 2*MACSize
+
+	// MessageResponseSize is the size of an encoded MessageResponse.
+	MessageResponseSize = 4 + 4 + 4 + ppk.KeySize + encryptedNothingSize + 2*MACSize
+
+	// MessageCookieReplySize is the size of an encoded MessageCookieReply.
+	MessageCookieReplySize = 4 + 4 + chacha.NonceSizeX + encryptedCookieSize
+
+	// MessageTransportMinSize is the size of an encoded MessageTransport
+	// carrying an empty session packet.
+	MessageTransportMinSize = 4 + 4 + SessionOverhead
+)
+
+// MessageInitiation is the first handshake message, sent by the sender to the
+// recipient. On the wire it is encoded as:
+//
+//	type (1) | reserved (3) | sender (4) | ephemeral (32) | static (48) |
+//	timestamp (28) | mac1 (16) | mac2 (16)
+//
+// All integers are little endian and reserved bytes must be zero.
+type MessageInitiation struct {
+	Sender    uint32
+	Ephemeral ppk.PublicKey
+	Static    EncryptedKey
+	Timestamp EncryptedTimestamp
+	MAC1      [MACSize]byte
+	MAC2      [MACSize]byte
+}
+
+// MessageResponse is the second handshake message, sent by the recipient in
+// reply to a MessageInitiation. On the wire it is encoded as:
+//
+//	type (1) | reserved (3) | sender (4) | receiver (4) | ephemeral (32) |
+//	empty (16) | mac1 (16) | mac2 (16)
+//
+// All integers are little endian and reserved bytes must be zero.
+type MessageResponse struct {
+	Sender    uint32
+	Receiver  uint32
+	Ephemeral ppk.PublicKey
+	Empty     EncryptedNothing
+	MAC1      [MACSize]byte
+	MAC2      [MACSize]byte
+}
+
+// MessageCookieReply is sent by a recipient under load in place of a
+// MessageResponse, carrying an encrypted cookie the sender must use for the
+// mac2 field of its next handshake message. On the wire it is encoded as:
+//
+//	type (1) | reserved (3) | receiver (4) | nonce (24) | cookie (32)
+//
+// All integers are little endian and reserved bytes must be zero.
+type MessageCookieReply struct {
+	Receiver uint32
+	Nonce    [chacha.NonceSizeX]byte
+	Cookie   [encryptedCookieSize]byte
+}
+
+// MessageTransport carries a packet sealed by a Session once the handshake is
+// complete. On the wire it is encoded as:
+//
+//	type (1) | reserved (3) | receiver (4) | packet (24+)
+//
+// All integers are little endian and reserved bytes must be zero.
+type MessageTransport struct {
+	Receiver uint32
+	Packet   []byte
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (msg *MessageInitiation) MarshalBinary() ([]byte, error) {
+	data := make([]byte, MessageInitiationSize)
+
+	data[0] = MessageTypeInitiation
+	binary.LittleEndian.PutUint32(data[4:], msg.Sender)
+
+	offset := 8
+	offset += copy(data[offset:], msg.Ephemeral[:])
+	offset += copy(data[offset:], msg.Static[:])
+	offset += copy(data[offset:], msg.Timestamp[:])
+	offset += copy(data[offset:], msg.MAC1[:])
+	copy(data[offset:], msg.MAC2[:])
+
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (msg *MessageInitiation) UnmarshalBinary(data []byte) error {
+	if len(data) != MessageInitiationSize {
+		return ErrInvalidMessageSize
+	}
+	if err := checkMessageHeader(data, MessageTypeInitiation); err != nil {
+		return err
+	}
+
+	msg.Sender = binary.LittleEndian.Uint32(data[4:])
+
+	offset := 8
+	offset += copy(msg.Ephemeral[:], data[offset:])
+	offset += copy(msg.Static[:], data[offset:])
+	offset += copy(msg.Timestamp[:], data[offset:])
+	offset += copy(msg.MAC1[:], data[offset:])
+	copy(msg.MAC2[:], data[offset:])
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (msg *MessageResponse) MarshalBinary() ([]byte, error) {
+	data := make([]byte, MessageResponseSize)
+
+	data[0] = MessageTypeResponse
+	binary.LittleEndian.PutUint32(data[4:], msg.Sender)
+	binary.LittleEndian.PutUint32(data[8:], msg.Receiver)
+
+	offset := 12
+	offset += copy(data[offset:], msg.Ephemeral[:])
+	offset += copy(data[offset:], msg.Empty[:])
+	offset += copy(data[offset:], msg.MAC1[:])
+	copy(data[offset:], msg.MAC2[:])
+
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (msg *MessageResponse) UnmarshalBinary(data []byte) error {
+	if len(data) != MessageResponseSize {
+		return ErrInvalidMessageSize
+	}
+	if err := checkMessageHeader(data, MessageTypeResponse); err != nil {
+		return err
+	}
+
+	msg.Sender = binary.LittleEndian.Uint32(data[4:])
+	msg.Receiver = binary.LittleEndian.Uint32(data[8:])
+
+	offset := 12
+	offset += copy(msg.Ephemeral[:], data[offset:])
+	offset += copy(msg.Empty[:], data[offset:])
+	offset += copy(msg.MAC1[:], data[offset:])
+	copy(msg.MAC2[:], data[offset:])
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (msg *MessageCookieReply) MarshalBinary() ([]byte, error) {
+	data := make([]byte, MessageCookieReplySize)
+
+	data[0] = MessageTypeCookieReply
+	binary.LittleEndian.PutUint32(data[4:], msg.Receiver)
+
+	offset := 8
+	offset += copy(data[offset:], msg.Nonce[:])
+	copy(data[offset:], msg.Cookie[:])
+
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (msg *MessageCookieReply) UnmarshalBinary(data []byte) error {
+	if len(data) != MessageCookieReplySize {
+		return ErrInvalidMessageSize
+	}
+	if err := checkMessageHeader(data, MessageTypeCookieReply); err != nil {
+		return err
+	}
+
+	msg.Receiver = binary.LittleEndian.Uint32(data[4:])
+
+	offset := 8
+	offset += copy(msg.Nonce[:], data[offset:])
+	copy(msg.Cookie[:], data[offset:])
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (msg *MessageTransport) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8+len(msg.Packet))
+
+	data[0] = MessageTypeTransport
+	binary.LittleEndian.PutUint32(data[4:], msg.Receiver)
+	copy(data[8:], msg.Packet)
+
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The packet is copied
+// out of data.
+func (msg *MessageTransport) UnmarshalBinary(data []byte) error {
+	if len(data) < MessageTransportMinSize {
+		return ErrInvalidMessageSize
+	}
+	if err := checkMessageHeader(data, MessageTypeTransport); err != nil {
+		return err
+	}
+
+	msg.Receiver = binary.LittleEndian.Uint32(data[4:])
+	msg.Packet = append(msg.Packet[:0], data[8:]...)
+
+	return nil
+}
+
+func checkMessageHeader(data []byte, msgType byte) error {
+	if data[0] != msgType {
+		return ErrInvalidMessageType
+	}
+	if data[1]|data[2]|data[3] != 0 {
+		return ErrInvalidMessageType
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageInitiationEncoding(t *testing.T) {
+	t.Parallel()
+
+	var msg, out MessageInitiation
+
+	msg.Sender = 0xDEADBEEF
+	copy(msg.Ephemeral[:], crypt.RandomBytes(ppk.KeySize))
+	copy(msg.Static[:], crypt.RandomBytes(encryptedKeySize))
+	copy(msg.Timestamp[:], crypt.RandomBytes(encryptedTimestampSize))
+	copy(msg.MAC1[:], crypt.RandomBytes(MACSize))
+	copy(msg.MAC2[:], crypt.RandomBytes(MACSize))
+
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, MessageInitiationSize)
+	assert.Equal(t, MessageTypeInitiation, data[0])
+
+	assert.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, msg, out)
+
+	assert.Equal(t, ErrInvalidMessageSize, out.UnmarshalBinary(nil))
+	assert.Equal(t, ErrInvalidMessageSize, out.UnmarshalBinary(data[1:]))
+	assert.Equal(t, ErrInvalidMessageSize,
+		out.UnmarshalBinary(append(data, 0)))
+
+	data[0] = MessageTypeResponse
+	assert.Equal(t, ErrInvalidMessageType, out.UnmarshalBinary(data))
+	data[0] = MessageTypeInitiation
+	data[2] = 1
+	assert.Equal(t, ErrInvalidMessageType, out.UnmarshalBinary(data))
+}
+
+func TestMessageResponseEncoding(t *testing.T) {
+	t.Parallel()
+
+	var msg, out MessageResponse
+
+	msg.Sender = 0xDEADBEEF
+	msg.Receiver = 0xCAFEBABE
+	copy(msg.Ephemeral[:], crypt.RandomBytes(ppk.KeySize))
+	copy(msg.Empty[:], crypt.RandomBytes(encryptedNothingSize))
+	copy(msg.MAC1[:], crypt.RandomBytes(MACSize))
+	copy(msg.MAC2[:], crypt.RandomBytes(MACSize))
+
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, MessageResponseSize)
+	assert.Equal(t, MessageTypeResponse, data[0])
+
+	assert.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, msg, out)
+
+	assert.Equal(t, ErrInvalidMessageSize, out.UnmarshalBinary(nil))
+	assert.Equal(t, ErrInvalidMessageSize, out.UnmarshalBinary(data[1:]))
+
+	data[0] = MessageTypeInitiation
+	assert.Equal(t, ErrInvalidMessageType, out.UnmarshalBinary(data))
+}
+
+func TestMessageTransportEncoding(t *testing.T) {
+	t.Parallel()
+
+	var msg, out MessageTransport
+
+	msg.Receiver = 0xDEADBEEF
+	msg.Packet = crypt.RandomBytes(SessionOverhead + 42)
+
+	data, err := msg.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, 8+len(msg.Packet))
+	assert.Equal(t, MessageTypeTransport, data[0])
+
+	assert.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, msg, out)
+
+	assert.Equal(t, ErrInvalidMessageSize,
+		out.UnmarshalBinary(data[:MessageTransportMinSize-1]))
+	assert.NoError(t, out.UnmarshalBinary(data[:MessageTransportMinSize]))
+
+	data[0] = MessageTypeInitiation
+	assert.Equal(t, ErrInvalidMessageType, out.UnmarshalBinary(data))
+}
+
+func TestHandshakeOverWire(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sHandshake Handshake
+		rHandshake Handshake
+		sSec       ppk.PrivateKey
+		sPub       ppk.PublicKey
+		rSec       ppk.PrivateKey
+		rPub       ppk.PublicKey
+		sPubOut    ppk.PublicKey
+		ts         Timestamp
+		initiation MessageInitiation
+		response   MessageResponse
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+
+	// sender
+	assert.NoError(t, sHandshake.InitializeSender(&rPub))
+	assert.NoError(t, sHandshake.Exchange(&sPub, &initiation.Static))
+	ts = NewTimestamp(time.Now())
+	assert.NoError(t, sHandshake.ExchangeTimestamp(
+		&sSec, &rPub, &ts, &initiation.Timestamp))
+	initiation.Sender = 1
+	initiation.Ephemeral = sHandshake.PublicKey()
+
+	data, err := initiation.MarshalBinary()
+	assert.NoError(t, err)
+
+	// recipient
+	var recvInitiation MessageInitiation
+	assert.NoError(t, recvInitiation.UnmarshalBinary(data))
+	assert.NoError(t, rHandshake.InitializeRecipient(
+		&rSec, &recvInitiation.Ephemeral))
+	assert.NoError(t, rHandshake.Exchange(&sPubOut, &recvInitiation.Static))
+	assert.Equal(t, sPub, sPubOut)
+	assert.NoError(t, rHandshake.ExchangeTimestamp(
+		&rSec, &sPubOut, &ts, &recvInitiation.Timestamp))
+
+	assert.NoError(t, rHandshake.PrepareRecipientResponse(
+		&recvInitiation.Ephemeral, &sPubOut, &response.Empty))
+	response.Sender = 2
+	response.Receiver = recvInitiation.Sender
+	response.Ephemeral = rHandshake.PublicKey()
+
+	data, err = response.MarshalBinary()
+	assert.NoError(t, err)
+
+	// sender
+	var recvResponse MessageResponse
+	assert.NoError(t, recvResponse.UnmarshalBinary(data))
+	assert.Equal(t, initiation.Sender, recvResponse.Receiver)
+	assert.NoError(t, sHandshake.ConsumeRecipientResponse(
+		&sSec, &recvResponse.Ephemeral, &recvResponse.Empty))
+
+	sSession, err := NewSession(&sHandshake)
+	assert.NoError(t, err)
+	rSession, err := NewSession(&rHandshake)
+	assert.NoError(t, err)
+
+	packet, err := sSession.Seal([]byte("We attack at dawn"))
+	assert.NoError(t, err)
+	msg, err := rSession.Open(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("We attack at dawn"), msg)
+}
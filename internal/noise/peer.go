@@ -0,0 +1,194 @@
+package noise
+
+import (
+	"sync"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// Peer is a remote party identified by its static public key, along with the
+// state kept about it. A Peer is safe for concurrent use.
+type Peer struct {
+	lock sync.RWMutex
+
+	publicKey    ppk.PublicKey
+	presharedKey [ppk.KeySize]byte
+	allowed      bool
+
+	lastHandshake time.Time
+	keyring       *Keyring
+}
+
+// PeerTable is a registry of peers keyed by their static public key. A
+// recipient handshake configured with a PeerTable rejects any sender not
+// present and allowed in it. The keyrings of all peers in the table follow
+// the table session policy and clock. A PeerTable is safe for concurrent use.
+type PeerTable struct {
+	lock  sync.RWMutex
+	peers map[ppk.PublicKey]*Peer
+
+	policy SessionPolicy
+	clock  Clock
+}
+
+// NewPeer returns an allowed peer with the given static public key and
+// optional preshared key.
+func NewPeer(pub *ppk.PublicKey, psk *[ppk.KeySize]byte) *Peer {
+	peer := &Peer{
+		publicKey: *pub,
+		allowed:   true,
+		keyring:   NewKeyring(&DefaultSessionPolicy, SystemClock),
+	}
+	if psk != nil {
+		peer.presharedKey = *psk
+	}
+
+	return peer
+}
+
+// PublicKey returns the static public key of the peer.
+func (p *Peer) PublicKey() ppk.PublicKey {
+	return p.publicKey
+}
+
+// PresharedKey returns the preshared key used in handshakes with the peer.
+func (p *Peer) PresharedKey() [ppk.KeySize]byte {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.presharedKey
+}
+
+// Allowed returns true if handshakes from the peer are accepted.
+func (p *Peer) Allowed() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.allowed
+}
+
+// SetAllowed changes whether handshakes from the peer are accepted.
+func (p *Peer) SetAllowed(allowed bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.allowed = allowed
+}
+
+// LastHandshake returns the time the last session with the peer was set, or
+// the zero time if there was none.
+func (p *Peer) LastHandshake() time.Time {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.lastHandshake
+}
+
+// Session returns the active session with the peer, or nil.
+func (p *Peer) Session() *Session {
+	return p.keyring.Current()
+}
+
+// SetSession replaces the active session with the peer and records the
+// handshake time. The replaced session is kept in the peer keyring.
+func (p *Peer) SetSession(session *Session) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.keyring.Rotate(session)
+	p.lastHandshake = time.Now()
+}
+
+// Keyring returns the keyring holding the sessions with the peer.
+func (p *Peer) Keyring() *Keyring {
+	return p.keyring
+}
+
+// NewPeerTable returns an empty peer table using the default session policy
+// and the system clock.
+func NewPeerTable() *PeerTable {
+	return NewPeerTableWithPolicy(&DefaultSessionPolicy, SystemClock)
+}
+
+// NewPeerTableWithPolicy returns an empty peer table whose peer keyrings
+// expire sessions according to the given policy as measured by the clock.
+func NewPeerTableWithPolicy(policy *SessionPolicy, clock Clock) *PeerTable {
+	return &PeerTable{
+		peers:  make(map[ppk.PublicKey]*Peer),
+		policy: *policy,
+		clock:  clock,
+	}
+}
+
+// Policy returns the session policy of the table.
+func (pt *PeerTable) Policy() SessionPolicy {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+
+	return pt.policy
+}
+
+// SetPolicy changes the session policy of the table and of all the peer
+// keyrings in it.
+func (pt *PeerTable) SetPolicy(policy *SessionPolicy) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	pt.policy = *policy
+	for _, peer := range pt.peers {
+		peer.keyring.SetPolicy(policy)
+	}
+}
+
+// Add inserts the peer in the table, failing if a peer with the same public
+// key is already present. The peer keyring takes the table policy and clock.
+func (pt *PeerTable) Add(peer *Peer) error {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	if _, ok := pt.peers[peer.publicKey]; ok {
+		return ErrPeerExists
+	}
+	peer.keyring.configure(&pt.policy, pt.clock)
+	pt.peers[peer.publicKey] = peer
+
+	return nil
+}
+
+// Remove deletes the peer with the given public key from the table.
+func (pt *PeerTable) Remove(pub *ppk.PublicKey) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	delete(pt.peers, *pub)
+}
+
+// Lookup returns the peer with the given public key, or nil.
+func (pt *PeerTable) Lookup(pub *ppk.PublicKey) *Peer {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+
+	return pt.peers[*pub]
+}
+
+// Peers returns all the peers in the table, in no particular order.
+func (pt *PeerTable) Peers() []*Peer {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+
+	peers := make([]*Peer, 0, len(pt.peers))
+	for _, peer := range pt.peers {
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
+
+// Len returns the number of peers in the table.
+func (pt *PeerTable) Len() int {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+
+	return len(pt.peers)
+}
@@ -0,0 +1,167 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerTable(t *testing.T) {
+	t.Parallel()
+
+	var (
+		pub0 = ppk.PublicKey{0}
+		pub1 = ppk.PublicKey{1}
+		psk  = [ppk.KeySize]byte{42}
+	)
+
+	pt := NewPeerTable()
+	assert.Zero(t, pt.Len())
+	assert.Nil(t, pt.Lookup(&pub0))
+
+	peer0 := NewPeer(&pub0, nil)
+	peer1 := NewPeer(&pub1, &psk)
+
+	assert.NoError(t, pt.Add(peer0))
+	assert.NoError(t, pt.Add(peer1))
+	assert.Equal(t, ErrPeerExists, pt.Add(NewPeer(&pub0, nil)))
+	assert.Equal(t, 2, pt.Len())
+	assert.ElementsMatch(t, []*Peer{peer0, peer1}, pt.Peers())
+
+	assert.Equal(t, peer0, pt.Lookup(&pub0))
+	assert.Equal(t, pub1, pt.Lookup(&pub1).PublicKey())
+	assert.Equal(t, psk, pt.Lookup(&pub1).PresharedKey())
+	assert.Equal(t, [ppk.KeySize]byte{}, pt.Lookup(&pub0).PresharedKey())
+
+	pt.Remove(&pub0)
+	assert.Nil(t, pt.Lookup(&pub0))
+	assert.Equal(t, 1, pt.Len())
+}
+
+func TestPeerTablePolicy(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	policy := DefaultSessionPolicy
+	policy.KeepPreviousTime = time.Minute
+
+	pt := NewPeerTableWithPolicy(&policy, clock)
+	assert.Equal(t, policy, pt.Policy())
+
+	peer := NewPeer(&ppk.PublicKey{}, nil)
+	assert.NoError(t, pt.Add(peer))
+
+	sOld, _ := sessionPairWithPolicy(t, &policy, clock)
+	sNew, _ := sessionPairWithPolicy(t, &policy, clock)
+	peer.SetSession(sOld)
+	peer.SetSession(sNew)
+
+	// the keyring follows the table clock and policy
+	clock.Advance(time.Second)
+	assert.Equal(t, sOld, peer.Keyring().Previous())
+	clock.Advance(time.Minute)
+	assert.Nil(t, peer.Keyring().Previous())
+
+	// policy changes reach peers already in the table
+	peer.SetSession(sOld)
+	policy.KeepPreviousTime = time.Second
+	pt.SetPolicy(&policy)
+	assert.Equal(t, policy, pt.Policy())
+	assert.Equal(t, sNew, peer.Keyring().Previous())
+	clock.Advance(time.Second)
+	assert.Nil(t, peer.Keyring().Previous())
+}
+
+func TestPeerState(t *testing.T) {
+	t.Parallel()
+
+	peer := NewPeer(&ppk.PublicKey{}, nil)
+	assert.True(t, peer.Allowed())
+	assert.Nil(t, peer.Session())
+	assert.True(t, peer.LastHandshake().IsZero())
+
+	peer.SetAllowed(false)
+	assert.False(t, peer.Allowed())
+
+	sSession, _ := sessionPair(t)
+	peer.SetSession(sSession)
+	assert.Equal(t, sSession, peer.Session())
+	assert.False(t, peer.LastHandshake().IsZero())
+}
+
+func TestHandshakePeerTable(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sSec ppk.PrivateKey
+		sPub ppk.PublicKey
+		rSec ppk.PrivateKey
+		rPub ppk.PublicKey
+		psk  [ppk.KeySize]byte
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+	copy(psk[:], crypt.RandomBytes(ppk.KeySize))
+
+	pt := NewPeerTable()
+
+	handshake := func() (*Handshake, error) {
+		var (
+			sHandshake Handshake
+			rHandshake Handshake
+			sPubOut    ppk.PublicKey
+			sPubEnc    EncryptedKey
+			ts         = NewTimestamp(time.Now())
+			tsEnc      EncryptedTimestamp
+			enc        EncryptedNothing
+		)
+
+		assert.NoError(t, sHandshake.SetPresharedKey(&psk))
+		assert.NoError(t, sHandshake.InitializeSender(&rPub))
+		assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
+		assert.NoError(t, sHandshake.ExchangeTimestamp(&sSec, &rPub, &ts, &tsEnc))
+		sPubTmp := sHandshake.PublicKey()
+
+		assert.NoError(t, rHandshake.SetPeerTable(pt))
+		assert.NoError(t, rHandshake.InitializeRecipient(&rSec, &sPubTmp))
+		if err := rHandshake.Exchange(&sPubOut, &sPubEnc); err != nil {
+			return &rHandshake, err
+		}
+		assert.NoError(t, rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &ts, &tsEnc))
+		assert.NoError(t, rHandshake.PrepareRecipientResponse(&sPubTmp, &sPubOut, &enc))
+		rPubTmp := rHandshake.PublicKey()
+
+		return &rHandshake, sHandshake.ConsumeRecipientResponse(&sSec, &rPubTmp, &enc)
+	}
+
+	// unknown sender
+	rHandshake, err := handshake()
+	assert.Equal(t, ErrUnknownPeer, err)
+	assert.Nil(t, rHandshake.Peer())
+
+	// known sender, preshared key is taken from the table
+	peer := NewPeer(&sPub, &psk)
+	assert.NoError(t, pt.Add(peer))
+
+	rHandshake, err = handshake()
+	assert.NoError(t, err)
+	assert.Equal(t, peer, rHandshake.Peer())
+
+	// disallowed sender
+	peer.SetAllowed(false)
+	_, err = handshake()
+	assert.Equal(t, ErrUnknownPeer, err)
+
+	// known sender with a different preshared key
+	pt.Remove(&sPub)
+	assert.NoError(t, pt.Add(NewPeer(&sPub, nil)))
+	_, err = handshake()
+	assert.Error(t, err)
+}
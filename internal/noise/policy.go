@@ -0,0 +1,37 @@
+package noise
+
+import "time"
+
+const (
+	// RekeyAfterTime is the age after which a session should be replaced by
+	// a new handshake.
+	RekeyAfterTime = 2 * time.Minute
+
+	// RejectAfterTime is the age after which a session refuses to seal or
+	// open any more packets.
+	RejectAfterTime = 3 * time.Minute
+
+	// KeepPreviousTime is how long a replaced session is still used to open
+	// packets which were in flight during the rotation.
+	KeepPreviousTime = 10 * time.Second
+)
+
+// SessionPolicy sets the lifetime limits of sessions. A session should be
+// replaced after RekeyAfterTime or RekeyAfterMessages, whichever comes first,
+// and stops working after RejectAfterTime or RejectAfterMessages.
+type SessionPolicy struct {
+	RekeyAfterTime      time.Duration
+	RejectAfterTime     time.Duration
+	KeepPreviousTime    time.Duration
+	RekeyAfterMessages  uint64
+	RejectAfterMessages uint64
+}
+
+// DefaultSessionPolicy is the policy used by NewSession.
+var DefaultSessionPolicy = SessionPolicy{
+	RekeyAfterTime:      RekeyAfterTime,
+	RejectAfterTime:     RejectAfterTime,
+	KeepPreviousTime:    KeepPreviousTime,
+	RekeyAfterMessages:  RekeyAfterMessages,
+	RejectAfterMessages: RejectAfterMessages,
+}
@@ -0,0 +1,47 @@
+package noise
+
+const (
+	replayWordBits    = 64
+	replayWindowWords = 32
+	replayWindowSize  = (replayWindowWords - 1) * replayWordBits
+)
+
+// replayWindow is a sliding bitmap of recently accepted counters, following
+// the scheme described in RFC 6479. Counters older than the window or already
+// marked are rejected.
+type replayWindow struct {
+	greatest uint64
+	bitmap   [replayWindowWords]uint64
+}
+
+// check returns true if the counter has not been seen before and is within
+// the window, marking it as seen.
+func (rw *replayWindow) check(counter uint64) bool {
+	if counter+replayWindowSize < rw.greatest {
+		return false
+	}
+
+	index := counter / replayWordBits
+
+	if counter > rw.greatest {
+		current := rw.greatest / replayWordBits
+		diff := index - current
+		if diff > replayWindowWords {
+			diff = replayWindowWords
+		}
+		for iter := uint64(1); iter <= diff; iter++ {
+			rw.bitmap[(current+iter)%replayWindowWords] = 0
+		}
+		rw.greatest = counter
+	}
+
+	index %= replayWindowWords
+	mask := uint64(1) << (counter % replayWordBits)
+
+	if rw.bitmap[index]&mask != 0 {
+		return false
+	}
+	rw.bitmap[index] |= mask
+
+	return true
+}
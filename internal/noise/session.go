@@ -0,0 +1,177 @@
+package noise
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	chacha "golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/poly1305"
+)
+
+const (
+	// RekeyAfterMessages is the number of messages after which a session
+	// should be replaced by a new handshake.
+	RekeyAfterMessages uint64 = 1 << 60
+
+	// RejectAfterMessages is the number of messages after which a session
+	// refuses to seal or open any more packets.
+	RejectAfterMessages uint64 = ^uint64(0) - (1 << 13)
+)
+
+const (
+	counterSize = 8
+
+	// SessionOverhead is the number of bytes a sealed packet adds on top of
+	// its plaintext.
+	SessionOverhead = counterSize + poly1305.TagSize
+)
+
+// Session is a transport session established by a completed handshake. It
+// seals and opens packets using ChaCha20-Poly1305 with a 64-bit counter
+// nonce. Each sealed packet is prefixed by the little endian counter used
+// for its nonce. Sessions expire according to their SessionPolicy. A Session
+// is safe for concurrent use.
+type Session struct {
+	policy  SessionPolicy
+	clock   Clock
+	created time.Time
+
+	sendLock    sync.Mutex
+	sendCounter uint64
+	send        cipher.AEAD
+
+	recvLock sync.Mutex
+	replay   replayWindow
+	recv     cipher.AEAD
+}
+
+// NewSession finalizes the given handshake and returns a session using the
+// resulting transport keys and the default policy.
+func NewSession(hs *Handshake) (*Session, error) {
+	return NewSessionWithPolicy(hs, &DefaultSessionPolicy, SystemClock)
+}
+
+// NewSessionWithPolicy finalizes the given handshake and returns a session
+// using the resulting transport keys, expiring according to the given policy
+// as measured by the clock.
+func NewSessionWithPolicy(hs *Handshake, policy *SessionPolicy, clock Clock) (*Session, error) {
+	var send, recv [ppk.KeySize]byte
+	defer crypt.ZeroBytes(send[:], recv[:])
+
+	if err := hs.Finalize(&send, &recv); err != nil {
+		return nil, err
+	}
+
+	sendCipher, err := chacha.New(send[:])
+	if err != nil {
+		return nil, err
+	}
+	recvCipher, err := chacha.New(recv[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		policy:  *policy,
+		clock:   clock,
+		created: clock.Now(),
+		send:    sendCipher,
+		recv:    recvCipher,
+	}, nil
+}
+
+// Seal encrypts and authenticates the plaintext, returning a packet ready to
+// be sent to the peer.
+func (s *Session) Seal(plaintext []byte) ([]byte, error) {
+	if s.age() >= s.policy.RejectAfterTime {
+		return nil, ErrSessionExpired
+	}
+
+	s.sendLock.Lock()
+	counter := s.sendCounter
+	if counter >= s.policy.RejectAfterMessages {
+		s.sendLock.Unlock()
+		return nil, ErrSessionExpired
+	}
+	s.sendCounter++
+	s.sendLock.Unlock()
+
+	var nonce [chacha.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+
+	packet := make([]byte, counterSize, counterSize+len(plaintext)+poly1305.TagSize)
+	binary.LittleEndian.PutUint64(packet, counter)
+
+	return s.send.Seal(packet, nonce[:], plaintext, nil), nil
+}
+
+// Open authenticates and decrypts a packet produced by the peer's Seal.
+// Packets which fail authentication, were already opened or fall behind the
+// replay window are rejected.
+func (s *Session) Open(packet []byte) ([]byte, error) {
+	if len(packet) < SessionOverhead {
+		return nil, ErrShortPacket
+	}
+
+	counter := binary.LittleEndian.Uint64(packet[:counterSize])
+	if counter >= s.policy.RejectAfterMessages ||
+		s.age() >= s.policy.RejectAfterTime {
+		return nil, ErrSessionExpired
+	}
+
+	var nonce [chacha.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], counter)
+
+	plaintext, err := s.recv.Open(nil, nonce[:], packet[counterSize:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recvLock.Lock()
+	defer s.recvLock.Unlock()
+
+	if !s.replay.check(counter) {
+		return nil, ErrReplayedPacket
+	}
+
+	return plaintext, nil
+}
+
+// NeedsRekey returns true once the session is old enough or has sealed
+// enough messages that a new handshake should be performed.
+func (s *Session) NeedsRekey() bool {
+	if s.age() >= s.policy.RekeyAfterTime {
+		return true
+	}
+
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	return s.sendCounter >= s.policy.RekeyAfterMessages
+}
+
+// Expired returns true once the session can no longer seal packets.
+func (s *Session) Expired() bool {
+	if s.age() >= s.policy.RejectAfterTime {
+		return true
+	}
+
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	return s.sendCounter >= s.policy.RejectAfterMessages
+}
+
+// Created returns the time the session was established.
+func (s *Session) Created() time.Time {
+	return s.created
+}
+
+func (s *Session) age() time.Duration {
+	return s.clock.Now().Sub(s.created)
+}
@@ -0,0 +1,120 @@
+package noise
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sessionPair(t *testing.T) (sSession, rSession *Session) {
+	sHandshake, rHandshake := handshakePair(t)
+
+	sSession, err := NewSession(sHandshake)
+	assert.NoError(t, err)
+	rSession, err = NewSession(rHandshake)
+	assert.NoError(t, err)
+
+	return sSession, rSession
+}
+
+func TestSessionFlow(t *testing.T) {
+	t.Parallel()
+
+	sSession, rSession := sessionPair(t)
+
+	msg := []byte("We attack at dawn")
+
+	packet, err := sSession.Seal(msg)
+	assert.NoError(t, err)
+	assert.Len(t, packet, len(msg)+SessionOverhead)
+
+	out, err := rSession.Open(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, out)
+
+	packet, err = rSession.Seal(msg)
+	assert.NoError(t, err)
+
+	out, err = sSession.Open(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, out)
+
+	// a session can't open its own packets
+	_, err = rSession.Open(packet)
+	assert.Error(t, err)
+}
+
+func TestSessionBadState(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewSession(new(Handshake))
+	assert.Equal(t, ErrBadHandshakeState, err)
+}
+
+func TestSessionOpenInvalid(t *testing.T) {
+	t.Parallel()
+
+	sSession, rSession := sessionPair(t)
+
+	_, err := rSession.Open(nil)
+	assert.Equal(t, ErrShortPacket, err)
+	_, err = rSession.Open(make([]byte, SessionOverhead-1))
+	assert.Equal(t, ErrShortPacket, err)
+
+	packet, err := sSession.Seal([]byte("We attack at dawn"))
+	assert.NoError(t, err)
+
+	packet[len(packet)-1] ^= 0xFF
+	_, err = rSession.Open(packet)
+	assert.Error(t, err)
+}
+
+func TestSessionReplay(t *testing.T) {
+	t.Parallel()
+
+	sSession, rSession := sessionPair(t)
+
+	packets := make([][]byte, replayWindowSize+replayWordBits)
+	for index := range packets {
+		packet, err := sSession.Seal([]byte{byte(index)})
+		assert.NoError(t, err)
+		packets[index] = packet
+	}
+
+	// out of order delivery is accepted once
+	_, err := rSession.Open(packets[10])
+	assert.NoError(t, err)
+	_, err = rSession.Open(packets[5])
+	assert.NoError(t, err)
+	_, err = rSession.Open(packets[10])
+	assert.Equal(t, ErrReplayedPacket, err)
+	_, err = rSession.Open(packets[5])
+	assert.Equal(t, ErrReplayedPacket, err)
+
+	// packets which fell behind the window are rejected
+	_, err = rSession.Open(packets[len(packets)-1])
+	assert.NoError(t, err)
+	_, err = rSession.Open(packets[0])
+	assert.Equal(t, ErrReplayedPacket, err)
+	_, err = rSession.Open(packets[len(packets)-replayWindowSize])
+	assert.NoError(t, err)
+}
+
+func TestSessionLimits(t *testing.T) {
+	t.Parallel()
+
+	sSession, rSession := sessionPair(t)
+	assert.False(t, sSession.NeedsRekey())
+
+	sSession.sendCounter = RekeyAfterMessages
+	assert.True(t, sSession.NeedsRekey())
+
+	packet, err := sSession.Seal(nil)
+	assert.NoError(t, err)
+	_, err = rSession.Open(packet)
+	assert.NoError(t, err)
+
+	sSession.sendCounter = RejectAfterMessages
+	_, err = sSession.Seal(nil)
+	assert.Equal(t, ErrSessionExpired, err)
+}
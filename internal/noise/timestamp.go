@@ -0,0 +1,61 @@
+package noise
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+const (
+	// TimestampSize is the size of an encoded TAI64N timestamp.
+	TimestampSize = 12
+
+	tai64Base = uint64(1<<62) + 10
+)
+
+// Timestamp is a TAI64N timestamp, as sent encrypted in handshake initiations
+// to protect recipients against replayed messages. Encoded timestamps compare
+// in the same order as the time they represent.
+type Timestamp [TimestampSize]byte
+
+// NewTimestamp returns the TAI64N encoding of the given time.
+func NewTimestamp(t time.Time) (ts Timestamp) {
+	binary.BigEndian.PutUint64(ts[:8], tai64Base+uint64(t.Unix()))
+	binary.BigEndian.PutUint32(ts[8:], uint32(t.Nanosecond()))
+
+	return ts
+}
+
+// After returns true if the timestamp is strictly greater than the other.
+func (ts *Timestamp) After(other *Timestamp) bool {
+	return bytes.Compare(ts[:], other[:]) > 0
+}
+
+// TimestampFilter keeps the greatest timestamp seen for each peer static key.
+// Recipients use it to reject replayed handshake initiations. A
+// TimestampFilter is safe for concurrent use.
+type TimestampFilter struct {
+	lock     sync.Mutex
+	greatest map[ppk.PublicKey]Timestamp
+}
+
+// Check returns ErrReplayedTimestamp if the timestamp is not greater than the
+// greatest timestamp seen for the given peer, otherwise records it.
+func (tf *TimestampFilter) Check(pub *ppk.PublicKey, ts *Timestamp) error {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+
+	if tf.greatest == nil {
+		tf.greatest = make(map[ppk.PublicKey]Timestamp)
+	}
+
+	if greatest, ok := tf.greatest[*pub]; ok && !ts.After(&greatest) {
+		return ErrReplayedTimestamp
+	}
+	tf.greatest[*pub] = *ts
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampOrder(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	ts0 := NewTimestamp(now)
+	ts1 := NewTimestamp(now.Add(time.Nanosecond))
+	ts2 := NewTimestamp(now.Add(time.Second))
+
+	assert.True(t, ts1.After(&ts0))
+	assert.True(t, ts2.After(&ts1))
+	assert.False(t, ts0.After(&ts1))
+	assert.False(t, ts0.After(&ts0))
+
+	assert.Equal(t, ts0, NewTimestamp(now))
+}
+
+func TestTimestampFilter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tf   TimestampFilter
+		pub0 ppk.PublicKey
+		pub1 = ppk.PublicKey{1}
+	)
+
+	now := time.Now()
+	ts0 := NewTimestamp(now)
+	ts1 := NewTimestamp(now.Add(time.Second))
+
+	assert.NoError(t, tf.Check(&pub0, &ts0))
+	assert.Equal(t, ErrReplayedTimestamp, tf.Check(&pub0, &ts0))
+	assert.NoError(t, tf.Check(&pub1, &ts0))
+	assert.NoError(t, tf.Check(&pub0, &ts1))
+	assert.Equal(t, ErrReplayedTimestamp, tf.Check(&pub0, &ts0))
+}
+
+func TestHandshakeReplayedInitiation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sHandshake Handshake
+		sSec       ppk.PrivateKey
+		sPub       ppk.PublicKey
+		rSec       ppk.PrivateKey
+		rPub       ppk.PublicKey
+		sPubEnc    EncryptedKey
+		tsEnc      EncryptedTimestamp
+		tf         TimestampFilter
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+
+	// captured initiation
+	ts := NewTimestamp(time.Now())
+	assert.NoError(t, sHandshake.InitializeSender(&rPub))
+	assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
+	assert.NoError(t, sHandshake.ExchangeTimestamp(&sSec, &rPub, &ts, &tsEnc))
+	sPubTmp := sHandshake.PublicKey()
+
+	consume := func() error {
+		var (
+			rHandshake Handshake
+			sPubOut    ppk.PublicKey
+			tsOut      Timestamp
+		)
+
+		assert.NoError(t, rHandshake.SetTimestampFilter(&tf))
+		assert.NoError(t, rHandshake.InitializeRecipient(&rSec, &sPubTmp))
+		assert.NoError(t, rHandshake.Exchange(&sPubOut, &sPubEnc))
+
+		err := rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &tsOut, &tsEnc)
+		if err != nil {
+			var enc EncryptedNothing
+			assert.Equal(t, ErrBadHandshakeState,
+				rHandshake.PrepareRecipientResponse(&sPubTmp, &sPubOut, &enc))
+		}
+
+		return err
+	}
+
+	assert.NoError(t, consume())
+	assert.Equal(t, ErrReplayedTimestamp, consume())
+}
+
+func TestHandshakeTimestampWrongStatic(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sHandshake Handshake
+		rHandshake Handshake
+		sSec       ppk.PrivateKey
+		sPub       ppk.PublicKey
+		rSec       ppk.PrivateKey
+		rPub       ppk.PublicKey
+		otherSec   ppk.PrivateKey
+		sPubOut    ppk.PublicKey
+		sPubEnc    EncryptedKey
+		ts         = NewTimestamp(time.Now())
+		tsEnc      EncryptedTimestamp
+	)
+
+	assert.NoError(t, ppk.NewPrivateKey(&sSec))
+	assert.NoError(t, sSec.PublicKey(&sPub))
+	assert.NoError(t, ppk.NewPrivateKey(&rSec))
+	assert.NoError(t, rSec.PublicKey(&rPub))
+	assert.NoError(t, ppk.NewPrivateKey(&otherSec))
+
+	// sender claims a static key it does not own
+	assert.NoError(t, sHandshake.InitializeSender(&rPub))
+	assert.NoError(t, sHandshake.Exchange(&sPub, &sPubEnc))
+	assert.NoError(t, sHandshake.ExchangeTimestamp(&otherSec, &rPub, &ts, &tsEnc))
+	sPubTmp := sHandshake.PublicKey()
+
+	assert.NoError(t, rHandshake.InitializeRecipient(&rSec, &sPubTmp))
+	assert.NoError(t, rHandshake.Exchange(&sPubOut, &sPubEnc))
+	assert.Error(t, rHandshake.ExchangeTimestamp(&rSec, &sPubOut, &ts, &tsEnc))
+}
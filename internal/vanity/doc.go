@@ -0,0 +1 @@
+package vanity // import "cpl.li/go/cryptor/internal/vanity"
@@ -0,0 +1,286 @@
+package vanity
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+)
+
+// DefaultProgressInterval is the interval between progress reports used when
+// none is given.
+const DefaultProgressInterval = time.Second
+
+var (
+	// ErrNoPattern is returned when searching without a prefix or a pattern.
+	ErrNoPattern = errors.New("no prefix or pattern to search for")
+
+	// ErrImpossiblePrefix is returned when the prefix can never be matched by
+	// an encoded public key.
+	ErrImpossiblePrefix = errors.New("prefix can not be matched by the encoding")
+)
+
+// Options configure a search. At least one of Prefix and Pattern must be set,
+// in which case public keys must match both.
+type Options struct {
+	// Encoding is the encoding of the public keys matched, Hex by default.
+	Encoding codec.Encoding
+
+	// Prefix is matched against the start of the encoded public key. It is
+	// lowercased for Hex and uppercased for Base32, which don't use the other
+	// letter case.
+	Prefix string
+
+	// Pattern is matched against the encoded public key.
+	Pattern *regexp.Regexp
+
+	// Workers is the number of concurrent workers, runtime.NumCPU() if not
+	// positive.
+	Workers int
+
+	// Rand is the source of the private keys, crypto/rand by default. Results
+	// are only deterministic for a given source with a single worker.
+	Rand io.Reader
+
+	// Progress, if set, is called every ProgressInterval during the search.
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+}
+
+// Progress describes a running search.
+type Progress struct {
+	// Attempts is the number of keys generated so far.
+	Attempts uint64
+
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration
+
+	// Rate is the number of keys generated per second.
+	Rate float64
+
+	// Expected is the expected number of attempts for a match, 0 if unknown
+	// because a pattern is used.
+	Expected float64
+
+	// ETA is the remaining time until Expected attempts are made at the
+	// current rate, 0 if unknown or already exceeded. Each attempt is
+	// independent, so a search may take considerably more or less.
+	ETA time.Duration
+}
+
+// Result is a key pair matching the search.
+type Result struct {
+	PrivateKey ppk.PrivateKey
+	PublicKey  ppk.PublicKey
+	Encoded    string
+
+	Attempts uint64
+	Elapsed  time.Duration
+}
+
+// ExpectedAttempts returns the expected number of keys to generate until one
+// matches the prefix with the given encoding. The estimate assumes every
+// character of the encoding is equally likely, which doesn't hold for the
+// first character of Base58 encoded keys.
+func ExpectedAttempts(enc codec.Encoding, prefix string) float64 {
+	return math.Pow(float64(len(enc.Alphabet())), float64(len(prefix)))
+}
+
+// Search generates key pairs until the encoded public key matches, returning
+// the first match. It stops early with the context error if ctx is done.
+func Search(ctx context.Context, opts Options) (*Result, error) {
+	prefix, err := opts.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	var expected float64
+	if opts.Pattern == nil {
+		expected = ExpectedAttempts(opts.Encoding, prefix)
+	}
+
+	match := func(encoded string) bool {
+		return strings.HasPrefix(encoded, prefix) &&
+			(opts.Pattern == nil || opts.Pattern.MatchString(encoded))
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	source := &lockedReader{r: opts.Rand}
+	if source.r == nil {
+		source.r = rand.Reader
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		start    = time.Now()
+		attempts uint64
+		found    = make(chan *Result, workers)
+		errs     = make(chan error, workers)
+		wg       sync.WaitGroup
+	)
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := search(ctx, source, opts.Encoding, match, &attempts)
+			if err != nil {
+				errs <- err
+			} else if result != nil {
+				found <- result
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var ticks <-chan time.Time
+	if opts.Progress != nil {
+		interval := opts.ProgressInterval
+		if interval <= 0 {
+			interval = DefaultProgressInterval
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case result := <-found:
+			cancel()
+			<-done
+
+			result.Attempts = atomic.LoadUint64(&attempts)
+			result.Elapsed = time.Since(start)
+
+			return result, nil
+		case err := <-errs:
+			cancel()
+			<-done
+
+			return nil, err
+		case <-ctx.Done():
+			<-done
+
+			return nil, ctx.Err()
+		case <-ticks:
+			opts.Progress(newProgress(atomic.LoadUint64(&attempts), time.Since(start), expected))
+		}
+	}
+}
+
+// search runs a single worker, returning nil without an error once ctx is
+// done.
+func search(ctx context.Context, source io.Reader, enc codec.Encoding,
+	match func(string) bool, attempts *uint64) (*Result, error) {
+	var result Result
+
+	for ctx.Err() == nil {
+		if err := ppk.NewPrivateKeyFrom(source, &result.PrivateKey); err != nil {
+			return nil, err
+		}
+		if err := result.PrivateKey.PublicKey(&result.PublicKey); err != nil {
+			return nil, err
+		}
+
+		atomic.AddUint64(attempts, 1)
+
+		if encoded := enc.Encode(result.PublicKey[:]); match(encoded) {
+			result.Encoded = encoded
+			return &result, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// prefix returns the normalized prefix, checking it can be matched.
+func (opts *Options) prefix() (string, error) {
+	if opts.Prefix == "" && opts.Pattern == nil {
+		return "", ErrNoPattern
+	}
+
+	prefix := opts.Prefix
+	switch opts.Encoding {
+	case codec.Hex:
+		prefix = strings.ToLower(prefix)
+	case codec.Base32:
+		prefix = strings.ToUpper(prefix)
+	}
+
+	alphabet := opts.Encoding.Alphabet()
+	if alphabet == "" {
+		return "", codec.ErrUnknownEncoding
+	}
+
+	encodedLen := len(opts.Encoding.Encode(make([]byte, ppk.KeySize)))
+	if len(prefix) > encodedLen {
+		return "", ErrImpossiblePrefix
+	}
+	for _, char := range prefix {
+		if !strings.ContainsRune(alphabet, char) {
+			return "", ErrImpossiblePrefix
+		}
+	}
+
+	return prefix, nil
+}
+
+func newProgress(attempts uint64, elapsed time.Duration, expected float64) Progress {
+	progress := Progress{
+		Attempts: attempts,
+		Elapsed:  elapsed,
+		Expected: expected,
+	}
+
+	if elapsed > 0 {
+		progress.Rate = float64(attempts) / elapsed.Seconds()
+	}
+	if progress.Rate > 0 && expected > float64(attempts) {
+		remaining := (expected - float64(attempts)) / progress.Rate
+		if remaining < math.MaxInt64/float64(time.Second) {
+			progress.ETA = time.Duration(remaining * float64(time.Second))
+		} else {
+			progress.ETA = math.MaxInt64
+		}
+	}
+
+	return progress
+}
+
+// lockedReader serializes reads from a source shared by the workers, filling
+// each read completely so a key is never made of interleaved reads.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (lr *lockedReader) Read(p []byte) (int, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return io.ReadFull(lr.r, p)
+}
@@ -0,0 +1,145 @@
+package vanity_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	mrand "math/rand"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"cpl.li/go/cryptor/internal/crypt/codec"
+	"cpl.li/go/cryptor/internal/crypt/ppk"
+	"cpl.li/go/cryptor/internal/vanity"
+)
+
+func checkResult(t *testing.T, enc codec.Encoding, result *vanity.Result) {
+	var pk ppk.PublicKey
+	assert.NoError(t, result.PrivateKey.PublicKey(&pk))
+	assert.True(t, pk.Equals(result.PublicKey))
+	assert.Equal(t, enc.Encode(pk[:]), result.Encoded)
+	assert.NotZero(t, result.Attempts)
+}
+
+func TestSearchDeterministic(t *testing.T) {
+	t.Parallel()
+
+	search := func() *vanity.Result {
+		result, err := vanity.Search(context.Background(), vanity.Options{
+			Prefix:  "A",
+			Workers: 1,
+			Rand:    mrand.New(mrand.NewSource(1)),
+		})
+		assert.NoError(t, err)
+		return result
+	}
+
+	first, second := search(), search()
+	checkResult(t, codec.Hex, first)
+	assert.True(t, strings.HasPrefix(first.Encoded, "a"))
+	assert.Equal(t, first.PrivateKey, second.PrivateKey)
+	assert.Equal(t, first.Attempts, second.Attempts)
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		enc    codec.Encoding
+		prefix string
+	}{
+		{codec.Hex, "ab"},
+		{codec.Base64, "+"},
+		{codec.Base64URL, "_"},
+		{codec.Base32, "q"},
+		{codec.Base58, "z"},
+	} {
+		result, err := vanity.Search(context.Background(), vanity.Options{
+			Encoding: test.enc,
+			Prefix:   test.prefix,
+			Workers:  4,
+		})
+		assert.NoError(t, err)
+		checkResult(t, test.enc, result)
+		assert.True(t, strings.HasPrefix(
+			strings.ToLower(result.Encoded), strings.ToLower(test.prefix)), test.enc)
+	}
+
+	pattern := regexp.MustCompile("1f$")
+	result, err := vanity.Search(context.Background(), vanity.Options{
+		Pattern: pattern,
+		Prefix:  "0",
+		Workers: 2,
+	})
+	assert.NoError(t, err)
+	checkResult(t, codec.Hex, result)
+	assert.True(t, strings.HasPrefix(result.Encoded, "0"))
+	assert.True(t, pattern.MatchString(result.Encoded))
+}
+
+func TestSearchCancel(t *testing.T) {
+	t.Parallel()
+
+	var reports uint32
+	var last atomic.Value
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	result, err := vanity.Search(ctx, vanity.Options{
+		Prefix:           "0123456789abcdef",
+		Workers:          2,
+		ProgressInterval: 10 * time.Millisecond,
+		Progress: func(progress vanity.Progress) {
+			atomic.AddUint32(&reports, 1)
+			last.Store(progress)
+		},
+	})
+	assert.Nil(t, result)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	assert.NotZero(t, atomic.LoadUint32(&reports))
+	progress := last.Load().(vanity.Progress)
+	assert.NotZero(t, progress.Attempts)
+	assert.NotZero(t, progress.Rate)
+	assert.Equal(t, vanity.ExpectedAttempts(codec.Hex, "0123456789abcdef"), progress.Expected)
+	assert.NotZero(t, progress.ETA)
+}
+
+func TestSearchInvalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		opts vanity.Options
+		err  error
+	}{
+		{vanity.Options{}, vanity.ErrNoPattern},
+		{vanity.Options{Prefix: "g"}, vanity.ErrImpossiblePrefix},
+		{vanity.Options{Prefix: strings.Repeat("0", 65)}, vanity.ErrImpossiblePrefix},
+		{vanity.Options{Encoding: codec.Base58, Prefix: "0"}, vanity.ErrImpossiblePrefix},
+		{vanity.Options{Encoding: codec.Base64, Prefix: "-"}, vanity.ErrImpossiblePrefix},
+		{vanity.Options{Encoding: codec.Encoding(0xFF), Prefix: "0"}, codec.ErrUnknownEncoding},
+		{vanity.Options{Prefix: "0123456789abcdef", Workers: 1,
+			Rand: bytes.NewReader(make([]byte, 40))}, io.ErrUnexpectedEOF},
+	} {
+		result, err := vanity.Search(ctx, test.opts)
+		assert.Nil(t, result)
+		assert.Equal(t, test.err, err)
+	}
+}
+
+func TestExpectedAttempts(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, float64(1), vanity.ExpectedAttempts(codec.Hex, ""))
+	assert.Equal(t, float64(256), vanity.ExpectedAttempts(codec.Hex, "ab"))
+	assert.Equal(t, float64(4096), vanity.ExpectedAttempts(codec.Base64, "ab"))
+	assert.Equal(t, float64(1024), vanity.ExpectedAttempts(codec.Base32, "AB"))
+}